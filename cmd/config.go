@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -37,3 +41,16 @@ func SetLevel(level string) error {
 	zapConfig.Level.SetLevel(parsedLevel)
 	return nil
 }
+
+// SetupTracing registers a global tracer provider that exports spans with OTLP.
+// The exporter is configured with the standard OTEL_EXPORTER_OTLP_* environment
+// variables.
+func SetupTracing() (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracegrpc.New(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("create trace exporter: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+	return tp, nil
+}
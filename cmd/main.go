@@ -1,16 +1,19 @@
 package main
 
 import (
+	"context"
 	"github.com/kong/pg-aurora-client/pkg/metrics"
 	"github.com/kong/pg-aurora-client/pkg/model"
 	"go.uber.org/zap"
 	"log"
-	"net/http"
+	"os"
 )
 
 type appContext struct {
 	Store  *model.Store
 	Logger *zap.Logger
+	// shuttingDown is set atomically once a termination signal was received
+	shuttingDown int32
 }
 
 func main() {
@@ -22,10 +25,31 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := run(pgc, logger); err != nil {
+		logger.Error("Application failed", zap.Error(err))
+		logger.Sync()
+		os.Exit(1)
+	}
+	logger.Sync()
+}
+
+// run serves until a termination signal, the deferred calls run in reverse order
+// once the store was shut down.
+func run(pgc *model.PgConfig, logger *zap.Logger) error {
+	// Spans are exported when OTEL_TRACES_EXPORTER is otlp
+	if os.Getenv("OTEL_TRACES_EXPORTER") == "otlp" {
+		tp, err := SetupTracing()
+		if err != nil {
+			return err
+		}
+		defer tp.Shutdown(context.Background())
+		pgc.SetTracerProvider(tp)
+	}
 	s, err := model.NewStore(logger, pgc)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	// Close is a no-op once serve shut the store down
 	defer s.Close()
 
 	ac := &appContext{
@@ -33,10 +57,18 @@ func main() {
 		Logger: logger,
 	}
 	// Initialize the metrics
-	err = metrics.InitMetricsClient(logger, "datadog")
+	metricsClient := os.Getenv("METRICS_CLIENT")
+	if metricsClient == "" {
+		metricsClient = "datadog"
+	}
+	err = metrics.InitMetricsClient(logger, metricsClient)
 	if err != nil {
 		logger.Error("Failed to initialize metrics", zap.Error(err))
 	}
-	ac.Logger.Info("Application is running on : 8080 .....")
-	http.ListenAndServe("0.0.0.0:8080", ac.routes())
+	defer func() {
+		if err := metrics.Close(); err != nil {
+			logger.Error("Failed to close metrics", zap.Error(err))
+		}
+	}()
+	return ac.serve()
 }
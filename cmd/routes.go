@@ -2,12 +2,20 @@ package main
 
 import (
 	"github.com/gorilla/mux"
+	"github.com/kong/pg-aurora-client/pkg/metrics"
+	"github.com/kong/pg-aurora-client/pkg/model"
 	"net/http"
 )
 
 func (ac *appContext) routes() http.Handler {
 	r := mux.NewRouter()
 	r.HandleFunc("/health", ac.getHealth).Methods("GET")
+	r.HandleFunc("/livez", ac.getLiveness).Methods("GET")
+	r.HandleFunc("/readyz", ac.getReadiness).Methods("GET")
+	// Only clients that are scraped, such as Prometheus, have a handler
+	if handler, err := metrics.CreateHandler(ac.Logger); err == nil {
+		r.Handle("/metrics", handler).Methods("GET")
+	}
 	// Aurora specific
 	r.HandleFunc("/replstatus", ac.getReplicationStatus).Methods("GET")
 	r.HandleFunc("/ro/replstatus", ac.getROReplicationStatus).Methods("GET")
@@ -28,12 +36,43 @@ func (ac *appContext) routes() http.Handler {
 }
 
 func (ac *appContext) getHealth(w http.ResponseWriter, _ *http.Request) {
+	if ac.isShuttingDown() {
+		err := ac.writeJSON(w, http.StatusServiceUnavailable, envelope{"status": "shutting down"}, nil)
+		if err != nil {
+			ac.logError(err)
+		}
+		return
+	}
 	err := ac.writeJSON(w, http.StatusOK, envelope{"status": "ok"}, nil)
 	if err != nil {
 		ac.logError(err)
 	}
 }
 
+func (ac *appContext) getLiveness(w http.ResponseWriter, _ *http.Request) {
+	ac.writeCheckReport(w, ac.Store.Liveness())
+}
+
+func (ac *appContext) getReadiness(w http.ResponseWriter, r *http.Request) {
+	report := ac.Store.Readiness(r.Context())
+	if ac.isShuttingDown() {
+		report.OK = false
+		report.Checks = append(report.Checks, model.Check{Name: "shutdown", Message: "shutting down"})
+	}
+	ac.writeCheckReport(w, report)
+}
+
+func (ac *appContext) writeCheckReport(w http.ResponseWriter, report model.CheckReport) {
+	status := http.StatusOK
+	if !report.OK {
+		status = http.StatusServiceUnavailable
+	}
+	err := ac.writeJSON(w, status, envelope{"ok": report.OK, "checks": report.Checks}, nil)
+	if err != nil {
+		ac.logError(err)
+	}
+}
+
 func (ac *appContext) getReplicationStatus(w http.ResponseWriter, _ *http.Request) {
 	status, err := ac.Store.GetReplicaStatus(false)
 	if err != nil {
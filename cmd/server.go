@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	serverAddr          = "0.0.0.0:8080"
+	serverReadTimeout   = time.Second * 10
+	serverWriteTimeout  = time.Second * 30
+	serverIdleTimeout   = time.Second * 120
+	readinessDrainDelay = time.Second * 5
+	// httpShutdownTimeout and storeShutdownTimeout fit in the default 30s
+	// termination grace period of Kubernetes with readinessDrainDelay
+	httpShutdownTimeout  = time.Second * 10
+	storeShutdownTimeout = time.Second * 10
+)
+
+func (ac *appContext) isShuttingDown() bool {
+	return atomic.LoadInt32(&ac.shuttingDown) == 1
+}
+
+// serve runs the HTTP server until it fails or SIGTERM/SIGINT is received, then
+// shuts the server and the store down.
+func (ac *appContext) serve() error {
+	srv := &http.Server{
+		Addr:         serverAddr,
+		Handler:      ac.routes(),
+		ReadTimeout:  serverReadTimeout,
+		WriteTimeout: serverWriteTimeout,
+		IdleTimeout:  serverIdleTimeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	ac.Logger.Info("Application is running on : 8080 .....")
+	select {
+	case err := <-serveErr:
+		return err
+	case sig := <-signals:
+		ac.Logger.Info("Shutting down", zap.Stringer("signal", sig))
+	}
+
+	// Fail readiness first so the endpoints stop routing new requests here
+	atomic.StoreInt32(&ac.shuttingDown, 1)
+	time.Sleep(readinessDrainDelay)
+
+	httpCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		ac.Logger.Error("HTTP server did not drain", zap.Error(err))
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		ac.Logger.Error("HTTP server failed", zap.Error(err))
+	}
+
+	storeCtx, cancel := context.WithTimeout(context.Background(), storeShutdownTimeout)
+	defer cancel()
+	report, err := ac.Store.Shutdown(storeCtx)
+	if err != nil {
+		ac.Logger.Error("Store did not drain", zap.Error(err), zap.Int("cancelled", report.Cancelled),
+			zap.Int("abandoned", report.Abandoned))
+	}
+	return nil
+}
@@ -91,4 +91,11 @@ Create database connection env vars
 - name: "ENABLE_TLS"
   value: "yes"
 {{- end }}
-{{- end }}
\ No newline at end of file
+{{- end }}
+
+{{- define "pg-aurora-client.metricsEnv" -}}
+{{- if .Values.metrics.client }}
+- name: "METRICS_CLIENT"
+  value: {{ .Values.metrics.client }}
+{{- end }}
+{{- end }}
@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 
 	"go.uber.org/zap"
 )
 
-var activeClient metricsClient = noopClient{}
+var (
+	clientMu     sync.RWMutex
+	activeClient metricsClient = noopClient{}
+)
 
 type Tag struct {
 	Key   string
@@ -44,6 +48,8 @@ const (
 	NoOp ClientType = iota
 	Datadog
 	Prometheus
+	OpenTelemetry
+	Recording
 )
 
 const (
@@ -54,6 +60,8 @@ var validClientTypes = map[string]ClientType{
 	"noop":       NoOp,
 	"datadog":    Datadog,
 	"prometheus": Prometheus,
+	"otel":       OpenTelemetry,
+	"recording":  Recording,
 }
 
 func ParseClientType(clientType string) (ClientType, error) {
@@ -82,6 +90,7 @@ func InitMetricsClient(logger *zap.Logger, clientType string) error {
 		return err
 	}
 
+	var client metricsClient
 	switch ct {
 	case Datadog:
 		agent := os.Getenv("DD_AGENT_HOST")
@@ -90,48 +99,75 @@ func InitMetricsClient(logger *zap.Logger, clientType string) error {
 		}
 
 		var err error
-		activeClient, err = newDatadogClient(logger.With(zap.String("component", "datadog")), agent)
+		client, err = newDatadogClient(logger.With(zap.String("component", "datadog")), agent)
+		if err != nil {
+			return err
+		}
+	case Prometheus:
+		client = newPrometheusClient(logger.With(zap.String("component", "prometheus")))
+	case OpenTelemetry:
+		var err error
+		client, err = newOTLPClient(logger.With(zap.String("component", "otel")))
 		if err != nil {
 			return err
 		}
+	case Recording:
+		client = NewRecordingClient()
 	case NoOp:
+		return nil
 	default:
 		return errors.New("metrics client config not set")
 	}
+	swapClient(client)
 	return nil
 }
 
+// swapClient replaces the active client and returns the previous one.
+func swapClient(c metricsClient) metricsClient {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	previous := activeClient
+	activeClient = c
+	return previous
+}
+
+func getClient() metricsClient {
+	clientMu.RLock()
+	defer clientMu.RUnlock()
+	return activeClient
+}
+
 func prefixMetricName(name string) string {
 	return fmt.Sprintf("%s_%s", metricPrefix, name)
 }
 
 // Gauge measures the value of a metric at a particular time.
 func Gauge(name string, value float64, tags ...Tag) {
-	activeClient.Gauge(prefixMetricName(name), value, tags...)
+	getClient().Gauge(prefixMetricName(name), value, tags...)
 }
 
 // GaugeAdd adds a new measure to a gauge.
 func GaugeAdd(name string, value float64, tags ...Tag) {
-	activeClient.GaugeAdd(prefixMetricName(name), value, tags...)
+	getClient().GaugeAdd(prefixMetricName(name), value, tags...)
 }
 
 // Count tracks how many times something happened.
 func Count(name string, value int64, tags ...Tag) {
-	activeClient.Count(prefixMetricName(name), value, tags...)
+	getClient().Count(prefixMetricName(name), value, tags...)
 }
 
 // Histogram tracks the statistical distribution of a set of values.
 func Histogram(name string, value float64, tags ...Tag) {
-	activeClient.Histogram(prefixMetricName(name), value, tags...)
+	getClient().Histogram(prefixMetricName(name), value, tags...)
 }
 
 // CreateHandler create an http.Handler if supported by the client.
 // Otherwise an error will be returned.
 func CreateHandler(log *zap.Logger) (http.Handler, error) {
-	return activeClient.CreateHandler(log)
+	return getClient().CreateHandler(log)
 }
 
 // Close the underlying client connection if supported.
 func Close() error {
-	return activeClient.Close()
+	return getClient().Close()
 }
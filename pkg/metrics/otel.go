@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+const otelMeterName = "github.com/kong/pg-aurora-client"
+
+type otelClient struct {
+	log        *zap.Logger
+	provider   *sdkmetric.MeterProvider
+	meter      metric.Meter
+	mu         sync.Mutex
+	gauges     map[string]*otelGauge
+	counters   map[string]instrument.Int64Counter
+	histograms map[string]instrument.Float64Histogram
+}
+
+// otelGauge keeps the last value of every attribute set. OpenTelemetry gauges are
+// asynchronous, so the values are reported when the reader collects them.
+type otelGauge struct {
+	mu     sync.Mutex
+	values map[attribute.Distinct]otelGaugeValue
+}
+
+type otelGaugeValue struct {
+	attrs []attribute.KeyValue
+	value float64
+}
+
+// newOTLPClient exports through OTLP over gRPC. The exporter is configured with the
+// standard OTEL_EXPORTER_OTLP_* environment variables.
+func newOTLPClient(logger *zap.Logger) (*otelClient, error) {
+	exporter, err := otlpmetricgrpc.New(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return newOtelClient(logger, sdkmetric.NewPeriodicReader(exporter)), nil
+}
+
+func newOtelClient(logger *zap.Logger, reader sdkmetric.Reader) *otelClient {
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	return &otelClient{
+		log:        logger,
+		provider:   provider,
+		meter:      provider.Meter(otelMeterName),
+		gauges:     map[string]*otelGauge{},
+		counters:   map[string]instrument.Int64Counter{},
+		histograms: map[string]instrument.Float64Histogram{},
+	}
+}
+
+func otelAttributes(tags []Tag) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(tags))
+	for _, tag := range tags {
+		attrs = append(attrs, attribute.String(tag.Key, tag.Value))
+	}
+	return attrs
+}
+
+func (c *otelClient) gauge(name string) (*otelGauge, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g, ok := c.gauges[name]
+	if !ok {
+		g = &otelGauge{values: map[attribute.Distinct]otelGaugeValue{}}
+		_, err := c.meter.Float64ObservableGauge(name, instrument.WithFloat64Callback(
+			func(_ context.Context, o instrument.Float64Observer) error {
+				g.mu.Lock()
+				defer g.mu.Unlock()
+				for _, v := range g.values {
+					o.Observe(v.value, v.attrs...)
+				}
+				return nil
+			}))
+		if err != nil {
+			return nil, err
+		}
+		c.gauges[name] = g
+	}
+	return g, nil
+}
+
+func (c *otelClient) counter(name string) (instrument.Int64Counter, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter, ok := c.counters[name]
+	if !ok {
+		var err error
+		counter, err = c.meter.Int64Counter(name)
+		if err != nil {
+			return nil, err
+		}
+		c.counters[name] = counter
+	}
+	return counter, nil
+}
+
+func (c *otelClient) histogram(name string) (instrument.Float64Histogram, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.histograms[name]
+	if !ok {
+		var err error
+		h, err = c.meter.Float64Histogram(name)
+		if err != nil {
+			return nil, err
+		}
+		c.histograms[name] = h
+	}
+	return h, nil
+}
+
+func (c *otelClient) updateGauge(name string, tags []Tag, update func(float64) float64) {
+	g, err := c.gauge(name)
+	if err != nil {
+		c.log.With(zap.Error(err)).Error("failed to update gauge", zap.String("name", name))
+		return
+	}
+	attrs := otelAttributes(tags)
+	set := attribute.NewSet(attrs...)
+	key := set.Equivalent()
+	g.mu.Lock()
+	g.values[key] = otelGaugeValue{attrs: attrs, value: update(g.values[key].value)}
+	g.mu.Unlock()
+}
+
+func (c *otelClient) Gauge(name string, value float64, tags ...Tag) {
+	c.updateGauge(name, tags, func(float64) float64 { return value })
+}
+
+func (c *otelClient) GaugeAdd(name string, value float64, tags ...Tag) {
+	c.updateGauge(name, tags, func(current float64) float64 { return current + value })
+}
+
+func (c *otelClient) Count(name string, value int64, tags ...Tag) {
+	if value < 0 {
+		c.log.Error("counters cannot decrease", zap.String("name", name), zap.Int64("value", value))
+		return
+	}
+	counter, err := c.counter(name)
+	if err != nil {
+		c.log.With(zap.Error(err)).Error("failed to update count", zap.String("name", name))
+		return
+	}
+	counter.Add(context.Background(), value, otelAttributes(tags)...)
+}
+
+func (c *otelClient) Histogram(name string, value float64, tags ...Tag) {
+	h, err := c.histogram(name)
+	if err != nil {
+		c.log.With(zap.Error(err)).Error("failed to update histogram", zap.String("name", name))
+		return
+	}
+	h.Record(context.Background(), value, otelAttributes(tags)...)
+}
+
+func (c *otelClient) CreateHandler(*zap.Logger) (http.Handler, error) {
+	return nil, errors.New("opentelemetry metrics are pushed by the exporter")
+}
+
+// Close flushes the pending metrics and shuts the exporter down.
+func (c *otelClient) Close() error {
+	return c.provider.Shutdown(context.Background())
+}
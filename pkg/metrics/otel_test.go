@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestOtelClient(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	c := newOtelClient(zap.NewNop(), reader)
+	c.Gauge("kong_pg_aurora_custom_replication_lag", 12.5, Tag{"pg_host", "rw"})
+	c.GaugeAdd("kong_pg_aurora_custom_replication_lag", 1, Tag{"pg_host", "rw"})
+	c.Count("kong_pg_aurora_custom_db_destroy_count", 1, Tag{"pg_host", "rw"})
+	c.Count("kong_pg_aurora_custom_db_destroy_count", 2, Tag{"pg_host", "rw"})
+	c.Count("kong_pg_aurora_custom_db_destroy_count", -1, Tag{"pg_host", "rw"})
+	c.Histogram("kong_pg_aurora_custom_query_duration", 3, Tag{"pg_host", "rw"})
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	collected := map[string]metricdata.Aggregation{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		collected[m.Name] = m.Data
+	}
+	host := attribute.NewSet(attribute.String("pg_host", "rw"))
+
+	gauge := collected["kong_pg_aurora_custom_replication_lag"].(metricdata.Gauge[float64])
+	require.Len(t, gauge.DataPoints, 1)
+	require.Equal(t, 13.5, gauge.DataPoints[0].Value)
+	require.Equal(t, host, gauge.DataPoints[0].Attributes)
+
+	sum := collected["kong_pg_aurora_custom_db_destroy_count"].(metricdata.Sum[int64])
+	require.Len(t, sum.DataPoints, 1)
+	require.Equal(t, int64(3), sum.DataPoints[0].Value, "negative counts are dropped")
+
+	histogram := collected["kong_pg_aurora_custom_query_duration"].(metricdata.Histogram)
+	require.Len(t, histogram.DataPoints, 1)
+	require.Equal(t, uint64(1), histogram.DataPoints[0].Count)
+
+	require.NoError(t, c.Close())
+}
@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+)
+
+// histogram values are expected in milliseconds, from 1ms to ~16s
+var defaultHistogramBuckets = prometheus.ExponentialBuckets(1, 2, 15)
+
+type prometheusClient struct {
+	log      *zap.Logger
+	registry *prometheus.Registry
+	mu       sync.Mutex
+	// kinds is the type of every metric name, emitting a name with another type fails
+	kinds map[string]string
+	// vecs holds a vector per metric name and label set, see vec
+	vecs       map[string]prometheus.Collector
+	registries prometheus.Gatherers
+}
+
+func newPrometheusClient(logger *zap.Logger) *prometheusClient {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return &prometheusClient{
+		log:        logger,
+		registry:   registry,
+		kinds:      map[string]string{},
+		vecs:       map[string]prometheus.Collector{},
+		registries: prometheus.Gatherers{registry},
+	}
+}
+
+func labelNames(tags []Tag) []string {
+	names := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		key := sanitizeName(tag.Key)
+		if !seen[key] {
+			seen[key] = true
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func labelValues(tags []Tag) prometheus.Labels {
+	labels := make(prometheus.Labels, len(tags))
+	for _, tag := range tags {
+		labels[sanitizeName(tag.Key)] = tag.Value
+	}
+	return labels
+}
+
+// vec returns the vector of the metric name with the label names of tags, created
+// with newVec. A registry refuses vectors of one name with different label names,
+// so every vector has its own registry and the series of all of them are exported
+// together. Emitting a name with another type than before is an error.
+func (c *prometheusClient) vec(kind, name string, tags []Tag,
+	newVec func(opts prometheus.Opts, labelNames []string) prometheus.Collector,
+) (prometheus.Collector, prometheus.Labels, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exported := sanitizeName(name)
+	if known, ok := c.kinds[exported]; ok && known != kind {
+		return nil, nil, fmt.Errorf("metric %s is a %s, not a %s", exported, known, kind)
+	}
+	names := labelNames(tags)
+	key := exported + "{" + strings.Join(names, ",") + "}"
+	vec, ok := c.vecs[key]
+	if !ok {
+		vec = newVec(prometheus.Opts{Name: exported, Help: exported}, names)
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(vec); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := c.kinds[exported]; ok {
+			c.log.Warn("metric emitted with another label set", zap.String("name", exported),
+				zap.Strings("labels", names))
+		}
+		c.kinds[exported] = kind
+		c.vecs[key] = vec
+		c.registries = append(c.registries, registry)
+	}
+	return vec, labelValues(tags), nil
+}
+
+func (c *prometheusClient) gauge(name string, tags []Tag) (prometheus.Gauge, error) {
+	vec, labels, err := c.vec("gauge", name, tags, func(opts prometheus.Opts, names []string) prometheus.Collector {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts(opts), names)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vec.(*prometheus.GaugeVec).GetMetricWith(labels)
+}
+
+func (c *prometheusClient) counter(name string, tags []Tag) (prometheus.Counter, error) {
+	vec, labels, err := c.vec("counter", name, tags, func(opts prometheus.Opts, names []string) prometheus.Collector {
+		return prometheus.NewCounterVec(prometheus.CounterOpts(opts), names)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vec.(*prometheus.CounterVec).GetMetricWith(labels)
+}
+
+func (c *prometheusClient) histogram(name string, tags []Tag) (prometheus.Observer, error) {
+	vec, labels, err := c.vec("histogram", name, tags, func(opts prometheus.Opts, names []string) prometheus.Collector {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    opts.Name,
+			Help:    opts.Help,
+			Buckets: defaultHistogramBuckets,
+		}, names)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vec.(*prometheus.HistogramVec).GetMetricWith(labels)
+}
+
+// Gather gathers the runtime metrics and the series of every vector.
+func (c *prometheusClient) Gather() ([]*dto.MetricFamily, error) {
+	c.mu.Lock()
+	registries := c.registries
+	c.mu.Unlock()
+	return registries.Gather()
+}
+
+func (c *prometheusClient) Gauge(name string, value float64, tags ...Tag) {
+	g, err := c.gauge(name, tags)
+	if err != nil {
+		c.log.With(zap.Error(err)).Error("failed to update gauge", zap.String("name", name))
+		return
+	}
+	g.Set(value)
+}
+
+func (c *prometheusClient) GaugeAdd(name string, value float64, tags ...Tag) {
+	g, err := c.gauge(name, tags)
+	if err != nil {
+		c.log.With(zap.Error(err)).Error("failed to update gauge", zap.String("name", name))
+		return
+	}
+	g.Add(value)
+}
+
+func (c *prometheusClient) Count(name string, value int64, tags ...Tag) {
+	if value < 0 {
+		c.log.Error("counters cannot decrease", zap.String("name", name), zap.Int64("value", value))
+		return
+	}
+	counter, err := c.counter(name, tags)
+	if err != nil {
+		c.log.With(zap.Error(err)).Error("failed to update count", zap.String("name", name))
+		return
+	}
+	counter.Add(float64(value))
+}
+
+func (c *prometheusClient) Histogram(name string, value float64, tags ...Tag) {
+	h, err := c.histogram(name, tags)
+	if err != nil {
+		c.log.With(zap.Error(err)).Error("failed to update histogram", zap.String("name", name))
+		return
+	}
+	h.Observe(value)
+}
+
+func (c *prometheusClient) CreateHandler(log *zap.Logger) (http.Handler, error) {
+	return promhttp.HandlerFor(c, promhttp.HandlerOpts{
+		ErrorLog: zap.NewStdLog(log.With(zap.String("component", "prometheus"))),
+	}), nil
+}
+
+func (c *prometheusClient) Close() error {
+	return nil
+}
+
+// sanitizeName replaces the characters Prometheus does not allow in metric and
+// label names with underscores.
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
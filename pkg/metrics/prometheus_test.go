@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPrometheusClient(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	c := newPrometheusClient(zap.New(core))
+	c.Gauge("kong_pg_aurora_custom_replication_lag", 12.5, Tag{"pg_host", "rw"})
+	c.Count("kong_pg_aurora_custom_db_destroy_count", 1, Tag{"pg_host", "rw"})
+	c.Count("kong_pg_aurora_custom_db_destroy_count", 2, Tag{"pg_host", "rw"})
+	c.Count("kong_pg_aurora_custom_db_destroy_count", 1, Tag{"pg_host", "ro"}, Tag{"pool_role", "ro"})
+	require.Equal(t, 1, logs.FilterMessage("metric emitted with another label set").Len())
+	c.Gauge("kong_pg_aurora_custom_db_destroy_count", 1)
+	require.Equal(t, 1, logs.FilterMessage("failed to update gauge").Len(), "a metric keeps its type")
+	c.Histogram("kong_pg_aurora_custom_query_duration", 3, Tag{"pg-host", "rw"})
+
+	handler, err := c.CreateHandler(zap.NewNop())
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, recorder.Code)
+	body, err := io.ReadAll(recorder.Result().Body)
+	require.NoError(t, err)
+
+	require.Contains(t, string(body), `kong_pg_aurora_custom_replication_lag{pg_host="rw"} 12.5`)
+	require.Contains(t, string(body), `kong_pg_aurora_custom_db_destroy_count{pg_host="rw"} 3`)
+	require.Contains(t, string(body), `kong_pg_aurora_custom_db_destroy_count{pg_host="ro",pool_role="ro"} 1`)
+	require.Contains(t, string(body), `kong_pg_aurora_custom_query_duration_count{pg_host="rw"} 1`)
+}
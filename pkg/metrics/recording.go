@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Emission kinds recorded by RecordingClient.
+const (
+	KindGauge     = "gauge"
+	KindGaugeAdd  = "gauge_add"
+	KindCount     = "count"
+	KindHistogram = "histogram"
+)
+
+// Emission is a single call to the metrics client.
+type Emission struct {
+	Kind  string
+	Name  string
+	Value float64
+	Tags  []Tag
+}
+
+// HasTags reports whether the emission has all the given tags.
+func (e Emission) HasTags(tags ...Tag) bool {
+	for _, want := range tags {
+		found := false
+		for _, tag := range e.Tags {
+			if tag == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordingClient keeps every emission in memory so tests can assert on them. The
+// emissions are never dropped, so it must not be deployed. Metric names given to the
+// query helpers are the ones passed to Gauge, Count, etc., without the package prefix.
+type RecordingClient struct {
+	mu        sync.Mutex
+	emissions []Emission
+}
+
+func NewRecordingClient() *RecordingClient {
+	return &RecordingClient{}
+}
+
+func (c *RecordingClient) record(kind, name string, value float64, tags []Tag) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.emissions = append(c.emissions, Emission{
+		Kind:  kind,
+		Name:  name,
+		Value: value,
+		Tags:  append([]Tag(nil), tags...),
+	})
+}
+
+func (c *RecordingClient) Gauge(name string, value float64, tags ...Tag) {
+	c.record(KindGauge, name, value, tags)
+}
+
+func (c *RecordingClient) GaugeAdd(name string, value float64, tags ...Tag) {
+	c.record(KindGaugeAdd, name, value, tags)
+}
+
+func (c *RecordingClient) Count(name string, value int64, tags ...Tag) {
+	c.record(KindCount, name, float64(value), tags)
+}
+
+func (c *RecordingClient) Histogram(name string, value float64, tags ...Tag) {
+	c.record(KindHistogram, name, value, tags)
+}
+
+func (c *RecordingClient) CreateHandler(*zap.Logger) (http.Handler, error) {
+	return nil, errors.New("recording client does not support http handler")
+}
+
+func (c *RecordingClient) Close() error {
+	return nil
+}
+
+// Emissions returns the emissions of name that have all the given tags, oldest first.
+func (c *RecordingClient) Emissions(name string, tags ...Tag) []Emission {
+	name = prefixMetricName(name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var emissions []Emission
+	for _, e := range c.emissions {
+		if e.Name == name && e.HasTags(tags...) {
+			emissions = append(emissions, e)
+		}
+	}
+	return emissions
+}
+
+// LastValue returns the most recent value of name with the given tags.
+func (c *RecordingClient) LastValue(name string, tags ...Tag) (float64, bool) {
+	emissions := c.Emissions(name, tags...)
+	if len(emissions) == 0 {
+		return 0, false
+	}
+	return emissions[len(emissions)-1].Value, true
+}
+
+// Sum adds up the values of name with the given tags.
+func (c *RecordingClient) Sum(name string, tags ...Tag) float64 {
+	sum := 0.0
+	for _, e := range c.Emissions(name, tags...) {
+		sum += e.Value
+	}
+	return sum
+}
+
+// Series groups the values of name by the value of the tag key. Emissions without
+// the tag are grouped under "".
+func (c *RecordingClient) Series(name, key string) map[string][]float64 {
+	series := map[string][]float64{}
+	for _, e := range c.Emissions(name) {
+		value := ""
+		for _, tag := range e.Tags {
+			if tag.Key == key {
+				value = tag.Value
+				break
+			}
+		}
+		series[value] = append(series[value], e.Value)
+	}
+	return series
+}
+
+// Reset drops the recorded emissions.
+func (c *RecordingClient) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.emissions = nil
+}
+
+// UseRecordingClient makes a new RecordingClient the active client and returns it
+// with a function that restores the previous client.
+func UseRecordingClient() (*RecordingClient, func()) {
+	c := NewRecordingClient()
+	previous := swapClient(c)
+	return c, func() { swapClient(previous) }
+}
+
+// ActiveRecordingClient returns the active client if it is a RecordingClient, e.g.
+// after InitMetricsClient with "recording".
+func ActiveRecordingClient() (*RecordingClient, bool) {
+	c, ok := getClient().(*RecordingClient)
+	return c, ok
+}
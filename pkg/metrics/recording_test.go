@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRecordingClient(t *testing.T) {
+	c, restore := UseRecordingClient()
+	defer restore()
+
+	Gauge("pg_aurora_custom_idle_conn", 3, Tag{"pg_host", "rw"}, Tag{"pool_role", "rw"})
+	Gauge("pg_aurora_custom_idle_conn", 5, Tag{"pg_host", "ro"}, Tag{"pool_role", "ro"})
+	Gauge("pg_aurora_custom_idle_conn", 4, Tag{"pg_host", "rw"}, Tag{"pool_role", "rw"})
+	Count("pg_aurora_custom_db_destroy_count", 1, Tag{"pg_host", "rw"})
+	Count("pg_aurora_custom_db_destroy_count", 2, Tag{"pg_host", "rw"})
+
+	value, ok := c.LastValue("pg_aurora_custom_idle_conn", Tag{"pool_role", "rw"})
+	require.True(t, ok)
+	require.Equal(t, 4.0, value)
+	_, ok = c.LastValue("pg_aurora_custom_max_conn")
+	require.False(t, ok)
+	require.Equal(t, 3.0, c.Sum("pg_aurora_custom_db_destroy_count"))
+	require.Equal(t, map[string][]float64{"rw": {3, 4}, "ro": {5}},
+		c.Series("pg_aurora_custom_idle_conn", "pg_host"))
+	require.Equal(t, KindCount, c.Emissions("pg_aurora_custom_db_destroy_count")[0].Kind)
+
+	c.Reset()
+	require.Empty(t, c.Emissions("pg_aurora_custom_idle_conn"))
+
+	restore()
+	_, ok = ActiveRecordingClient()
+	require.False(t, ok, "the previous client is restored")
+}
+
+func TestInitMetricsClient_Recording(t *testing.T) {
+	defer swapClient(getClient())
+	require.NoError(t, InitMetricsClient(zap.NewNop(), "recording"))
+	c, ok := ActiveRecordingClient()
+	require.True(t, ok)
+	Count("pg_aurora_custom_writer_change_count", 1)
+	require.Equal(t, 1.0, c.Sum("pg_aurora_custom_writer_change_count"))
+}
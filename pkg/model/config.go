@@ -3,13 +3,18 @@ package model
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"reflect"
+	"strconv"
+	"time"
 
 	defaultMetrics "github.com/kong/pg-aurora-client/pkg/metrics"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kong/pg-aurora-client/pkg/pool"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -22,6 +27,51 @@ type PgConfig struct {
 	port           string
 	enableTLS      bool
 	caBundleFSPath string
+	// topologyCheckPeriod is zero unless PG_TOPOLOGY_CHECK_PERIOD is set
+	topologyCheckPeriod time.Duration
+	// readerBalancing is enabled by PG_RO_BALANCE_STRATEGY and requires PG_RO_HOST
+	readerBalancing       bool
+	readerBalanceStrategy pool.ReaderBalanceStrategy
+	// tokenProvider replaces the static password when PG_AUTH_MODE is iam
+	tokenProvider TokenProvider
+	// credentials replaces the static password when PG_PASSWORD_FILE is set
+	credentials pool.CredentialsSource
+	// queryMetrics emits per-query latency and errors when PG_QUERY_METRICS is set
+	queryMetrics bool
+	// slowQuery logs slow queries when PG_SLOW_QUERY_THRESHOLD is set
+	slowQuery *pool.SlowQueryConfig
+	// acquireValidation validates idle connections on acquire when
+	// PG_ACQUIRE_VALIDATION_IDLE_TIME is set
+	acquireValidation *pool.AcquireValidationConfig
+	// circuitBreaker fails calls fast during outages when PG_CIRCUIT_BREAKER_THRESHOLD is set
+	circuitBreaker *pool.CircuitBreakerConfig
+	// healthPolicy is set by PG_HEALTH_POLICY, empty keeps the default thresholds
+	healthPolicy string
+	// lazyConnect returns the pools before they connected when PG_LAZY_CONNECT is set
+	lazyConnect bool
+	// maxReadyLag is set by PG_READY_MAX_REPLICATION_LAG, zero leaves the lag out of
+	// readiness
+	maxReadyLag time.Duration
+	// tracerProvider creates spans for the pool queries when set
+	tracerProvider trace.TracerProvider
+}
+
+// SetTokenProvider makes new connections authenticate with tokens from provider
+// instead of the static password.
+func (pgc *PgConfig) SetTokenProvider(provider TokenProvider) {
+	pgc.tokenProvider = provider
+}
+
+// SetCredentialsSource makes new connections use the password of source and
+// recycles connections when it is rotated.
+func (pgc *PgConfig) SetCredentialsSource(source pool.CredentialsSource) {
+	pgc.credentials = source
+}
+
+// SetTracerProvider makes the pools create a span for every query, batch, COPY,
+// connect and acquire.
+func (pgc *PgConfig) SetTracerProvider(provider trace.TracerProvider) {
+	pgc.tracerProvider = provider
 }
 
 var dsnNoTLS = "postgres://%s:%s@%s:%s/%s?sslmode=disable"
@@ -34,9 +84,15 @@ func validate(pgc *PgConfig) error {
 	if pgc.user == "" {
 		return fmt.Errorf("env variable PG_USER cannot be empty")
 	}
-	if pgc.password == "" {
+	if pgc.password == "" && pgc.tokenProvider == nil && pgc.credentials == nil {
 		return fmt.Errorf("env variable PG_PASSWORD cannot be empty")
 	}
+	if pgc.tokenProvider != nil && pgc.credentials != nil {
+		return fmt.Errorf("env variable PG_PASSWORD_FILE cannot be used when PG_AUTH_MODE is iam")
+	}
+	if pgc.tokenProvider != nil && !pgc.enableTLS {
+		return fmt.Errorf("env variable ENABLE_TLS must be true when PG_AUTH_MODE is iam")
+	}
 	if pgc.hostURL == "" {
 		return fmt.Errorf("env variable PG_HOST cannot be empty")
 	}
@@ -46,6 +102,9 @@ func validate(pgc *PgConfig) error {
 	if pgc.database == "" {
 		return fmt.Errorf("env variable PG_DATABASE cannot be empty")
 	}
+	if pgc.readerBalancing && pgc.roHostURL == "" {
+		return fmt.Errorf("env variable PG_RO_HOST cannot be empty when PG_RO_BALANCE_STRATEGY is set")
+	}
 	return nil
 }
 
@@ -66,6 +125,79 @@ func LoadPostgresConfig() (*PgConfig, error) {
 		enableTLS:      tls,
 		caBundleFSPath: caBundleFSPath,
 	}
+	if period := os.Getenv("PG_TOPOLOGY_CHECK_PERIOD"); period != "" {
+		d, err := time.ParseDuration(period)
+		if err != nil {
+			return nil, fmt.Errorf("env variable PG_TOPOLOGY_CHECK_PERIOD is invalid: %w", err)
+		}
+		pgc.topologyCheckPeriod = d
+	}
+	if maxLag := os.Getenv("PG_READY_MAX_REPLICATION_LAG"); maxLag != "" {
+		d, err := time.ParseDuration(maxLag)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("env variable PG_READY_MAX_REPLICATION_LAG must be a positive duration: %q", maxLag)
+		}
+		pgc.maxReadyLag = d
+	}
+	if queryMetrics := os.Getenv("PG_QUERY_METRICS"); queryMetrics == "yes" || queryMetrics == "true" {
+		pgc.queryMetrics = true
+	}
+	if lazyConnect := os.Getenv("PG_LAZY_CONNECT"); lazyConnect == "yes" || lazyConnect == "true" {
+		pgc.lazyConnect = true
+	}
+	if threshold := os.Getenv("PG_SLOW_QUERY_THRESHOLD"); threshold != "" {
+		slowQuery, err := loadSlowQueryConfig(threshold)
+		if err != nil {
+			return nil, err
+		}
+		pgc.slowQuery = slowQuery
+	}
+	if idleTime := os.Getenv("PG_ACQUIRE_VALIDATION_IDLE_TIME"); idleTime != "" {
+		d, err := time.ParseDuration(idleTime)
+		if err != nil {
+			return nil, fmt.Errorf("env variable PG_ACQUIRE_VALIDATION_IDLE_TIME is invalid: %w", err)
+		}
+		pgc.acquireValidation = &pool.AcquireValidationConfig{IdleTime: d}
+	}
+	if threshold := os.Getenv("PG_CIRCUIT_BREAKER_THRESHOLD"); threshold != "" {
+		n, err := strconv.Atoi(threshold)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("env variable PG_CIRCUIT_BREAKER_THRESHOLD must be a positive integer: %q", threshold)
+		}
+		pgc.circuitBreaker = &pool.CircuitBreakerConfig{FailureThreshold: n}
+	}
+	switch policy := os.Getenv("PG_HEALTH_POLICY"); policy {
+	case "", "threshold", "ratio", "consecutive":
+		pgc.healthPolicy = policy
+	default:
+		return nil, fmt.Errorf("env variable PG_HEALTH_POLICY is invalid: %q", policy)
+	}
+	if strategy := os.Getenv("PG_RO_BALANCE_STRATEGY"); strategy != "" {
+		s, err := pool.ParseReaderBalanceStrategy(strategy)
+		if err != nil {
+			return nil, fmt.Errorf("env variable PG_RO_BALANCE_STRATEGY is invalid: %w", err)
+		}
+		pgc.readerBalancing = true
+		pgc.readerBalanceStrategy = s
+	}
+	if passwordFile := os.Getenv("PG_PASSWORD_FILE"); passwordFile != "" {
+		pgc.credentials = pool.NewFileCredentials(passwordFile)
+	}
+	switch authMode := os.Getenv("PG_AUTH_MODE"); authMode {
+	case "", "password":
+	case "iam":
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			return nil, fmt.Errorf("env variable AWS_REGION cannot be empty when PG_AUTH_MODE is iam")
+		}
+		provider, err := newRDSAuthTokenProvider(context.Background(), pgc, region)
+		if err != nil {
+			return nil, err
+		}
+		pgc.tokenProvider = provider
+	default:
+		return nil, fmt.Errorf("env variable PG_AUTH_MODE is invalid: %q", authMode)
+	}
 
 	if err := validate(pgc); err != nil {
 		return nil, err
@@ -73,6 +205,41 @@ func LoadPostgresConfig() (*PgConfig, error) {
 	return pgc, nil
 }
 
+func loadSlowQueryConfig(threshold string) (*pool.SlowQueryConfig, error) {
+	d, err := time.ParseDuration(threshold)
+	if err != nil {
+		return nil, fmt.Errorf("env variable PG_SLOW_QUERY_THRESHOLD is invalid: %w", err)
+	}
+	config := &pool.SlowQueryConfig{Threshold: d}
+	if rate := os.Getenv("PG_SLOW_QUERY_SAMPLE_RATE"); rate != "" {
+		config.SampleRate, err = strconv.ParseFloat(rate, 64)
+		if err != nil || config.SampleRate < 0 || config.SampleRate > 1 {
+			return nil, fmt.Errorf("env variable PG_SLOW_QUERY_SAMPLE_RATE must be between 0 and 1: %q", rate)
+		}
+	}
+	config.Redaction, err = pool.ParseParamRedaction(os.Getenv("PG_SLOW_QUERY_PARAMS"))
+	if err != nil {
+		return nil, fmt.Errorf("env variable PG_SLOW_QUERY_PARAMS is invalid: %w", err)
+	}
+	return config, nil
+}
+
+// newHealthPolicy returns the constructor of the health policy of every pool, nil
+// keeps the default.
+func newHealthPolicy(name string) func() pool.HealthPolicy {
+	switch name {
+	case "ratio":
+		return func() pool.HealthPolicy {
+			return pool.RatioHealthPolicy{ResetRatio: defaultHealthResetRatio, MinValidated: 2}
+		}
+	case "consecutive":
+		return func() pool.HealthPolicy {
+			return pool.NewConsecutiveFailureHealthPolicy(defaultHealthResetAfter, defaultHealthEscalateAfter)
+		}
+	}
+	return nil
+}
+
 func getDSN(pgc *PgConfig) string {
 	var dsn string
 	if !pgc.enableTLS {
@@ -102,30 +269,46 @@ func getRODSN(pgc *PgConfig) string {
 }
 
 func metricsEmitter(metrics interface{}, tags []pool.MetricsTag) {
-	// they are all counters, but the MetricsEmitter can decide do what it needs
 	metricsTags := make([]defaultMetrics.Tag, 0, len(tags))
 	for _, tag := range tags {
 		metricsTags = append(metricsTags, defaultMetrics.Tag(tag))
 	}
 
 	switch reflect.TypeOf(metrics) {
-	case reflect.TypeOf(pgxpool.Stat{}):
-		stats := metrics.(pgxpool.Stat)
-		defaultMetrics.Count("pg_aurora_custom_idle_conn", int64(stats.IdleConns()), metricsTags...)
-		defaultMetrics.Count("pg_aurora_custom_acquired_conn", int64(stats.AcquiredConns()), metricsTags...)
-		defaultMetrics.Count("pg_aurora_custom_max_conn", int64(stats.MaxConns()), metricsTags...)
+	case reflect.TypeOf(pool.PoolStats{}):
+		stats := metrics.(pool.PoolStats)
+		// connection counts are point-in-time values
+		defaultMetrics.Gauge("pg_aurora_custom_idle_conn", float64(stats.IdleConns), metricsTags...)
+		defaultMetrics.Gauge("pg_aurora_custom_acquired_conn", float64(stats.AcquiredConns), metricsTags...)
+		defaultMetrics.Gauge("pg_aurora_custom_constructing_conn", float64(stats.ConstructingConns), metricsTags...)
+		defaultMetrics.Gauge("pg_aurora_custom_total_conn", float64(stats.TotalConns), metricsTags...)
+		defaultMetrics.Gauge("pg_aurora_custom_max_conn", float64(stats.MaxConns), metricsTags...)
+		// the rest are increases since the previous health check
+		defaultMetrics.Count("pg_aurora_custom_acquire_count", stats.AcquireCount, metricsTags...)
+		defaultMetrics.Count("pg_aurora_custom_acquire_duration_ms", stats.AcquireDuration.Milliseconds(),
+			metricsTags...)
+		defaultMetrics.Count("pg_aurora_custom_canceled_acquire_count", stats.CanceledAcquireCount, metricsTags...)
+		defaultMetrics.Count("pg_aurora_custom_empty_acquire_count", stats.EmptyAcquireCount, metricsTags...)
+		defaultMetrics.Count("pg_aurora_custom_new_conn_count", stats.NewConnsCount, metricsTags...)
+		defaultMetrics.Count("pg_aurora_custom_max_lifetime_destroy_count", stats.MaxLifetimeDestroyCount,
+			metricsTags...)
+		defaultMetrics.Count("pg_aurora_custom_max_idle_destroy_count", stats.MaxIdleDestroyCount, metricsTags...)
 	case reflect.TypeOf(pool.Metric{}):
 		metric := metrics.(pool.Metric)
 		defaultMetrics.Count(metric.Key, int64(metric.Value), metricsTags...)
+	case reflect.TypeOf(pool.HistogramMetric{}):
+		metric := metrics.(pool.HistogramMetric)
+		defaultMetrics.Histogram(metric.Key, metric.Value, metricsTags...)
 	}
 }
 
-func openPool(dsn string, pgc *PgConfig, logger *zap.Logger, validator pool.ValidationFunction) (pool.PGXConnPool, error) {
+func newPoolConfig(dsn string, pgc *PgConfig, logger *zap.Logger, validator pool.ValidationFunction,
+	role pool.PoolRole,
+) (*pool.Config, error) {
 	logger.Debug("DB connection:", zap.String("host", pgc.hostURL),
 		zap.Bool("Enable TLS", pgc.enableTLS),
 		zap.String("user", pgc.user), zap.String("port", pgc.port),
 		zap.String("database", pgc.database), zap.String("caBundlePath", pgc.caBundleFSPath))
-	ctx := context.Background()
 	config, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
@@ -133,15 +316,71 @@ func openPool(dsn string, pgc *PgConfig, logger *zap.Logger, validator pool.Vali
 
 	config.MaxConns = defaultMaxConnections
 	config.MinConns = defaultMinConnections
+	if pgc.tokenProvider != nil {
+		provider := pgc.tokenProvider
+		config.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+			token, _, err := provider.Token(ctx, net.JoinHostPort(cc.Host, strconv.Itoa(int(cc.Port))))
+			if err != nil {
+				return fmt.Errorf("generate auth token: %w", err)
+			}
+			cc.Password = token
+			return nil
+		}
+	}
+	if pgc.tracerProvider != nil {
+		config.ConnConfig.Tracer = pool.NewQueryTracer(pgc.tracerProvider, role)
+	}
 	apConfig := &pool.Config{
-		PGXConfig:      config,
-		QueryValidator: validator,
-		MetricsEmitter: metricsEmitter,
+		PGXConfig:           config,
+		QueryValidator:      validator,
+		MetricsEmitter:      metricsEmitter,
+		Role:                role,
+		TopologyCheckPeriod: pgc.topologyCheckPeriod,
+		Retry:               &pool.RetryConfig{},
+		Credentials:         pgc.credentials,
+		InstrumentQueries:   pgc.queryMetrics,
+		SlowQuery:           pgc.slowQuery,
+		NewHealthPolicy:     newHealthPolicy(pgc.healthPolicy),
+		AcquireValidation:   pgc.acquireValidation,
+		CircuitBreaker:      pgc.circuitBreaker,
+	}
+	if pgc.lazyConnect {
+		apConfig.LazyConnect = &pool.LazyConnectConfig{}
+	}
+	return apConfig, nil
+}
+
+func openPool(dsn string, pgc *PgConfig, logger *zap.Logger, validator pool.ValidationFunction,
+	role pool.PoolRole,
+) (pool.PGXConnPool, error) {
+	apConfig, err := newPoolConfig(dsn, pgc, logger, validator, role)
+	if err != nil {
+		return nil, err
 	}
 
-	dbpool, err := pool.NewAuroraPool(ctx, apConfig, logger)
+	dbpool, err := pool.NewAuroraPool(context.Background(), apConfig, logger)
 	if err != nil {
 		return nil, err
 	}
 	return dbpool, nil
 }
+
+// openReaderPool opens a pool per Aurora replica behind the reader endpoint.
+func openReaderPool(dsn string, pgc *PgConfig, logger *zap.Logger, validator pool.ValidationFunction) (pool.PGXConnPool, error) {
+	apConfig, err := newPoolConfig(dsn, pgc, logger, validator, pool.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	readerConfig := &pool.ReaderConfig{
+		Config:   apConfig,
+		Strategy: pgc.readerBalanceStrategy,
+		// Connections are opened per replica, keep the total close to a single pool
+		ReplicaMinConns: defaultReplicaMinConnections,
+	}
+
+	readerPool, err := pool.NewReaderPool(context.Background(), readerConfig, logger)
+	if err != nil {
+		return nil, err
+	}
+	return readerPool, nil
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kong/pg-aurora-client/pkg/metrics"
+	"github.com/kong/pg-aurora-client/pkg/pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsEmitter(t *testing.T) {
+	recorder, restore := metrics.UseRecordingClient()
+	defer restore()
+	tags := []pool.MetricsTag{{Key: "pg_host", Value: "rw"}, {Key: "pool_role", Value: "rw"}}
+
+	metricsEmitter(pool.Metric{Key: "pg_aurora_custom_db_destroy_count", Value: 1}, tags)
+	require.Equal(t, 1.0, recorder.Sum("pg_aurora_custom_db_destroy_count", metrics.Tag{Key: "pg_host", Value: "rw"}))
+
+	metricsEmitter(pool.PoolStats{IdleConns: 7, MaxConns: 50, AcquireCount: 3, AcquireDuration: time.Second}, tags)
+	idle := recorder.Emissions("pg_aurora_custom_idle_conn", metrics.Tag{Key: "pool_role", Value: "rw"})
+	require.Len(t, idle, 1)
+	require.Equal(t, metrics.KindGauge, idle[0].Kind)
+	require.Equal(t, 7.0, idle[0].Value)
+	require.Equal(t, 3.0, recorder.Sum("pg_aurora_custom_acquire_count"))
+	require.Equal(t, 1000.0, recorder.Sum("pg_aurora_custom_acquire_duration_ms"))
+
+	metricsEmitter(pool.HistogramMetric{Key: "pg_aurora_custom_query_duration_ms", Value: 2.5}, tags)
+	latency := recorder.Emissions("pg_aurora_custom_query_duration_ms")
+	require.Len(t, latency, 1)
+	require.Equal(t, metrics.KindHistogram, latency[0].Kind)
+	require.Equal(t, 2.5, latency[0].Value)
+}
+
+func TestValidate_IAMRequiresTLS(t *testing.T) {
+	pgc := &PgConfig{
+		user:          "koko",
+		hostURL:       "localhost",
+		port:          "5432",
+		database:      "koko",
+		tokenProvider: &stubTokenProvider{},
+	}
+	require.EqualError(t, validate(pgc), "env variable ENABLE_TLS must be true when PG_AUTH_MODE is iam")
+	pgc.enableTLS = true
+	require.NoError(t, validate(pgc))
+}
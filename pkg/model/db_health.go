@@ -4,19 +4,23 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/kong/pg-aurora-client/pkg/metrics"
 	"github.com/kong/pg-aurora-client/pkg/pool"
 	"go.uber.org/zap"
 )
 
 const (
-	defaultMaxConnections = 50
-	defaultMinConnections = 20
+	defaultMaxConnections        = 50
+	defaultMinConnections        = 20
+	defaultReplicaMinConnections = 5
+	defaultHealthResetRatio      = 0.5
+	defaultHealthResetAfter      = 3
+	defaultHealthEscalateAfter   = 10
 )
 
 var (
@@ -26,35 +30,52 @@ var (
 )
 
 type Store struct {
-	rwDBPool  pool.PGXConnPool
-	roDBPool  pool.PGXConnPool
-	Logger    *zap.Logger
-	closeChan chan struct{}
-	closeOnce sync.Once
+	rwDBPool       pool.PGXConnPool
+	roDBPool       pool.PGXConnPool
+	Logger         *zap.Logger
+	closeChan      chan struct{}
+	closeOnce      sync.Once
+	lagMu          sync.RWMutex
+	replicationLag time.Duration
+	lagMeasuredAt  time.Time
+	// lagCheckRunning is set atomically while backgroundLagCheck runs
+	lagCheckRunning int32
+	// maxReadyLag is the replication lag over which the store is not ready, zero
+	// leaves the lag out of readiness
+	maxReadyLag time.Duration
+	// migrated is set atomically once the required tables were found
+	migrated int32
 }
 
 func NewStore(logger *zap.Logger, pgc *PgConfig) (*Store, error) {
 	dsn := getDSN(pgc)
 	rodsn := getRODSN(pgc)
 
-	rwPool, err := openPool(dsn, pgc, logger, pool.DefaultWriteValidator)
+	rwPool, err := openPool(dsn, pgc, logger, pool.DefaultWriteValidator, pool.ReadWrite)
 	if err != nil {
 		return nil, err
 	}
 	logger.Info("established rw db connection to ", zap.String("host", rwPool.Config().ConnConfig.Host))
-	roPool, err := openPool(rodsn, pgc, logger, pool.DefaultReaderValidator)
+	var roPool pool.PGXConnPool
+	if pgc.readerBalancing {
+		roPool, err = openReaderPool(rodsn, pgc, logger, pool.DefaultReaderValidator)
+	} else {
+		roPool, err = openPool(rodsn, pgc, logger, pool.DefaultReaderValidator, pool.ReadOnly)
+	}
 	if err != nil {
 		return nil, err
 	}
 	logger.Info("established ro db connection to ", zap.String("host", roPool.Config().ConnConfig.Host))
 
 	store := &Store{
-		rwDBPool:  rwPool,
-		roDBPool:  roPool,
-		Logger:    logger,
-		closeChan: make(chan struct{}),
+		rwDBPool:    rwPool,
+		roDBPool:    roPool,
+		Logger:      logger,
+		closeChan:   make(chan struct{}),
+		maxReadyLag: pgc.maxReadyLag,
 	}
 	if store.rwDBPool != nil && store.roDBPool != nil {
+		atomic.StoreInt32(&store.lagCheckRunning, 1)
 		go store.backgroundLagCheck()
 	}
 
@@ -73,7 +94,52 @@ func (s *Store) Close() {
 	})
 }
 
+// shutdownPool is implemented by the pools that can drain in-flight work.
+type shutdownPool interface {
+	Shutdown(ctx context.Context) (pool.ShutdownReport, error)
+}
+
+// Shutdown stops the lag check and shuts both pools down concurrently, waiting for
+// in-flight work until ctx is done. The queries still running then are cancelled,
+// the returned report sums what both pools aborted.
+func (s *Store) Shutdown(ctx context.Context) (pool.ShutdownReport, error) {
+	var report pool.ShutdownReport
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for role, p := range map[string]pool.PGXConnPool{"rw": s.rwDBPool, "ro": s.roDBPool} {
+			if p == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(role string, p pool.PGXConnPool) {
+				defer wg.Done()
+				sp, ok := p.(shutdownPool)
+				if !ok {
+					p.Close()
+					return
+				}
+				poolReport, poolErr := sp.Shutdown(ctx)
+				s.Logger.Info("pool shut down", zap.String("pool_role", role),
+					zap.Int("in_flight", poolReport.InFlight), zap.Int("cancelled", poolReport.Cancelled),
+					zap.Int("abandoned", poolReport.Abandoned), zap.Duration("duration", poolReport.Duration))
+				mu.Lock()
+				defer mu.Unlock()
+				report = report.Add(poolReport)
+				if err == nil {
+					err = poolErr
+				}
+			}(role, p)
+		}
+		wg.Wait()
+	})
+	return report, err
+}
+
 func (s *Store) backgroundLagCheck() {
+	defer atomic.StoreInt32(&s.lagCheckRunning, 0)
 	ticker := time.NewTicker(defaultLagCheckFrequency)
 	defer ticker.Stop()
 	for {
@@ -83,6 +149,7 @@ func (s *Store) backgroundLagCheck() {
 			return
 		case <-ticker.C:
 			s.checkReadLag()
+			s.pruneSessionTokens()
 		}
 	}
 }
@@ -95,8 +162,7 @@ func (s *Store) checkReadLag() {
 	}
 	s.Logger.Info("updated replication canary", zap.Int64("ID", canary.ID),
 		zap.Time("update_ts", canary.LastUpdated))
-	cb := backoff.NewConstantBackOff(defaultBackoffInterval)
-	backoff.WithMaxRetries(cb, defaultLagReadRetries)
+	cb := backoff.WithMaxRetries(backoff.NewConstantBackOff(defaultBackoffInterval), defaultLagReadRetries)
 	err = backoff.Retry(func() error {
 		canaryRead, err := s.GetReplicationCanary()
 		if err != nil {
@@ -109,7 +175,7 @@ func (s *Store) checkReadLag() {
 				zap.Int64("read ID", canaryRead.ID))
 			return errors.New("write ID not found during read")
 		}
-		go metrics.Gauge("pg_aurora_custom_replication_lag", canaryRead.DiffMS)
+		s.recordReplicationLag(canaryRead.DiffMS)
 		s.Logger.Info("read lag measured", zap.Float64("duration_ms", canaryRead.DiffMS))
 		return nil
 	}, cb)
@@ -132,7 +198,7 @@ func (s *Store) GetReplicaStatus(ro bool) ([]ReplicaStatus, error) {
 	rsList := []ReplicaStatus{}
 	var rows pgx.Rows
 	var err error
-	ctx := context.Background()
+	ctx := pool.WithIdempotent(context.Background())
 	if ro && s.roDBPool != nil {
 		rows, err = s.roDBPool.Query(ctx, replicaStatusQuery)
 	} else {
@@ -208,8 +274,8 @@ func (s *Store) GetCanary() (*Canary, error) {
 	var canary Canary
 	var rows pgx.Rows
 	var err error
-	ctx := context.Background()
-	rows, err = s.roDBPool.Query(ctx, getCanaryQuery)
+	ctx := pool.WithIdempotent(context.Background())
+	rows, err = s.ReadPool(ctx).Query(ctx, getCanaryQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -260,11 +326,12 @@ func (s *Store) UpdateReplicationCanary() (*Canary, error) {
 }
 
 func (s *Store) GetReplicationCanary() (*Canary, error) {
+	return s.readReplicationCanary(context.Background(), s.roDBPool)
+}
+
+func (s *Store) readReplicationCanary(ctx context.Context, p pool.PGXConnPool) (*Canary, error) {
 	var canary Canary
-	var rows pgx.Rows
-	var err error
-	ctx := context.Background()
-	rows, err = s.roDBPool.Query(ctx, getReplicationCanaryQuery)
+	rows, err := p.Query(ctx, getReplicationCanaryQuery)
 	if err != nil {
 		return nil, err
 	}
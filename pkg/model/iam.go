@@ -0,0 +1,98 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+)
+
+// TokenProvider generates the password used by new connections to endpoint, the
+// host:port being connected to, e.g. an RDS IAM authentication token.
+type TokenProvider interface {
+	Token(ctx context.Context, endpoint string) (token string, expiresAt time.Time, err error)
+}
+
+const (
+	rdsAuthTokenValidity = time.Minute * 15
+	defaultTokenRefresh  = time.Minute * 5
+)
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+type cachingTokenProvider struct {
+	provider      TokenProvider
+	refreshBefore time.Duration
+	mu            sync.Mutex
+	tokens        map[string]cachedToken
+	now           func() time.Time
+}
+
+// NewCachingTokenProvider reuses the tokens of provider for each endpoint until
+// refreshBefore their expiry.
+func NewCachingTokenProvider(provider TokenProvider, refreshBefore time.Duration) TokenProvider {
+	return &cachingTokenProvider{
+		provider:      provider,
+		refreshBefore: refreshBefore,
+		tokens:        map[string]cachedToken{},
+		now:           time.Now,
+	}
+}
+
+func (c *cachingTokenProvider) Token(ctx context.Context, endpoint string) (string, time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.tokens[endpoint]; ok && c.now().Add(c.refreshBefore).Before(cached.expiresAt) {
+		return cached.token, cached.expiresAt, nil
+	}
+	token, expiresAt, err := c.provider.Token(ctx, endpoint)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	c.tokens[endpoint] = cachedToken{token: token, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// RDSAuthTokenProvider generates RDS IAM authentication tokens signed with the
+// credentials of Credentials.
+type RDSAuthTokenProvider struct {
+	Region      string
+	User        string
+	Credentials aws.CredentialsProvider
+	now         func() time.Time
+}
+
+func (r *RDSAuthTokenProvider) Token(ctx context.Context, endpoint string) (string, time.Time, error) {
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+	signedAt := now()
+	token, err := auth.BuildAuthToken(ctx, endpoint, r.Region, r.User, r.Credentials)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, signedAt.Add(rdsAuthTokenValidity), nil
+}
+
+// newRDSAuthTokenProvider returns the cached IAM token provider used when
+// PG_AUTH_MODE is iam. Credentials come from the default AWS credential chain, e.g.
+// web identity tokens of EKS service accounts, the instance role or env variables.
+func newRDSAuthTokenProvider(ctx context.Context, pgc *PgConfig, region string) (TokenProvider, error) {
+	awsConfig, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	return NewCachingTokenProvider(&RDSAuthTokenProvider{
+		Region:      region,
+		User:        pgc.user,
+		Credentials: awsConfig.Credentials,
+	}, defaultTokenRefresh), nil
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRDSAuthTokenProvider(t *testing.T) {
+	signedAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	provider := &RDSAuthTokenProvider{
+		Region: "us-west-2",
+		User:   "koko",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session"}, nil
+		}),
+		now: func() time.Time { return signedAt },
+	}
+	endpoint := "koko.cluster-abc.us-west-2.rds.amazonaws.com:5432"
+	token, expiresAt, err := provider.Token(context.Background(), endpoint)
+	require.NoError(t, err)
+	require.Equal(t, signedAt.Add(15*time.Minute), expiresAt)
+	require.True(t, strings.HasPrefix(token, endpoint+"?"))
+	require.Contains(t, token, "Action=connect")
+	require.Contains(t, token, "DBUser=koko")
+	require.Contains(t, token, "X-Amz-Credential=AKID%2F")
+	require.Contains(t, token, "%2Fus-west-2%2Frds-db%2Faws4_request")
+	require.Contains(t, token, "X-Amz-Expires=900")
+	require.Contains(t, token, "X-Amz-Security-Token=session")
+	require.Contains(t, token, "X-Amz-Signature=")
+}
+
+type stubTokenProvider struct {
+	calls int
+}
+
+func (s *stubTokenProvider) Token(_ context.Context, endpoint string) (string, time.Time, error) {
+	s.calls++
+	return endpoint, time.Now().Add(15 * time.Minute), nil
+}
+
+func TestCachingTokenProvider(t *testing.T) {
+	stub := &stubTokenProvider{}
+	provider := NewCachingTokenProvider(stub, 5*time.Minute).(*cachingTokenProvider)
+	ctx := context.Background()
+
+	token, _, err := provider.Token(ctx, "rw:5432")
+	require.NoError(t, err)
+	require.Equal(t, "rw:5432", token)
+	_, _, err = provider.Token(ctx, "rw:5432")
+	require.NoError(t, err)
+	require.Equal(t, 1, stub.calls, "tokens are reused until refresh")
+
+	_, _, err = provider.Token(ctx, "ro:5432")
+	require.NoError(t, err)
+	require.Equal(t, 2, stub.calls, "tokens are cached per endpoint")
+
+	provider.now = func() time.Time { return time.Now().Add(11 * time.Minute) }
+	_, _, err = provider.Token(ctx, "rw:5432")
+	require.NoError(t, err)
+	require.Equal(t, 3, stub.calls, "tokens are refreshed before expiry")
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"context"
 	"github.com/jackc/pgx/v5"
+	"github.com/kong/pg-aurora-client/pkg/pool"
 	"time"
 )
 
@@ -20,12 +21,8 @@ func (s *Store) GetMostRecentFoo() (*Foo, error) {
 	var foo Foo
 	var rows pgx.Rows
 	var err error
-	ctx := context.Background()
-	if s.roDBPool != nil {
-		rows, err = s.roDBPool.Query(ctx, getLastFooQuery)
-	} else {
-		rows, err = s.rwDBPool.Query(ctx, getLastFooQuery)
-	}
+	ctx := pool.WithIdempotent(context.Background())
+	rows, err = s.ReadPool(ctx).Query(ctx, getLastFooQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,187 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kong/pg-aurora-client/pkg/pool"
+)
+
+var readinessCheckTimeout = time.Second * 2
+
+// requiredTables are created by sql/db_script.sql, the migrations check fails until
+// they exist.
+var requiredTables = []string{"canary", "replication_canary", "session_token", "foo"}
+
+var tableExistsQuery = `SELECT to_regclass($1) IS NOT NULL`
+
+// Check is the outcome of a single liveness or readiness check.
+type Check struct {
+	Name      string  `json:"name"`
+	OK        bool    `json:"ok"`
+	LatencyMS float64 `json:"latencyMS"`
+	Message   string  `json:"message,omitempty"`
+}
+
+// CheckReport lists the checks of a liveness or readiness probe, OK is set when
+// every check passed.
+type CheckReport struct {
+	OK     bool    `json:"ok"`
+	Checks []Check `json:"checks"`
+}
+
+type checkFunc func(ctx context.Context) (bool, string)
+
+// runChecks runs the checks concurrently and reports them in order.
+func runChecks(ctx context.Context, names []string, checks []checkFunc) CheckReport {
+	report := CheckReport{OK: true, Checks: make([]Check, len(checks))}
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := time.Now()
+			ok, message := checks[i](ctx)
+			report.Checks[i] = Check{
+				Name:      names[i],
+				OK:        ok,
+				LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+				Message:   message,
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, check := range report.Checks {
+		report.OK = report.OK && check.OK
+	}
+	return report
+}
+
+// alivePool is implemented by the pools that run background loops.
+type alivePool interface {
+	Alive() error
+}
+
+// Liveness reports whether the store is open and the background loops of the store
+// and its pools are running. It does not touch the database, so an outage does not
+// restart the process.
+func (s *Store) Liveness() CheckReport {
+	names := []string{"store"}
+	checks := []checkFunc{func(context.Context) (bool, string) {
+		select {
+		case <-s.closeChan:
+			return false, "store is closed"
+		default:
+			return true, ""
+		}
+	}}
+	if s.rwDBPool != nil && s.roDBPool != nil {
+		names = append(names, "lag_check")
+		checks = append(checks, func(context.Context) (bool, string) {
+			if atomic.LoadInt32(&s.lagCheckRunning) == 0 {
+				return false, "background lag check is not running"
+			}
+			return true, ""
+		})
+	}
+	for _, pc := range []struct {
+		name string
+		pool pool.PGXConnPool
+	}{{"rw_pool", s.rwDBPool}, {"ro_pool", s.roDBPool}} {
+		if p, ok := pc.pool.(alivePool); ok {
+			names = append(names, pc.name)
+			checks = append(checks, func(context.Context) (bool, string) {
+				if err := p.Alive(); err != nil {
+					return false, err.Error()
+				}
+				return true, ""
+			})
+		}
+	}
+	return runChecks(context.Background(), names, checks)
+}
+
+// Readiness reports whether both pools are connected and not unavailable, and the
+// required tables exist. The pools are judged by their own health state, not by
+// querying the cluster, so a writer failover or a lag spike does not make every pod
+// unready at once while the other pool can still serve. The tables are only queried
+// until they were found once.
+//
+// The replication lag check is opt-in, with PG_READY_MAX_REPLICATION_LAG. It takes
+// every pod out of rotation together when the replicas fall behind, use it only
+// when serving stale reads is worse than serving none.
+func (s *Store) Readiness(ctx context.Context) CheckReport {
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+	names := []string{"rw_pool"}
+	checks := []checkFunc{poolCheck(s.rwDBPool)}
+	if s.roDBPool != nil {
+		names = append(names, "ro_pool")
+		checks = append(checks, poolCheck(s.roDBPool))
+		if s.maxReadyLag > 0 {
+			names = append(names, "replication_lag")
+			checks = append(checks, s.checkReplicationLag)
+		}
+	}
+	names = append(names, "migrations")
+	checks = append(checks, s.checkMigrations)
+	return runChecks(ctx, names, checks)
+}
+
+// healthReporter is implemented by the pools that track their health.
+type healthReporter interface {
+	Health() pool.PoolHealth
+}
+
+func poolCheck(p pool.PGXConnPool) checkFunc {
+	return func(context.Context) (bool, string) {
+		h, ok := p.(healthReporter)
+		if !ok {
+			return true, ""
+		}
+		health := h.Health()
+		if health.State == pool.Unavailable || health.State == pool.Connecting {
+			return false, fmt.Sprintf("pool is %s (%s)", health.State, health.Reason)
+		}
+		return true, health.State.String()
+	}
+}
+
+func (s *Store) checkReplicationLag(context.Context) (bool, string) {
+	lag, measuredAt := s.ReplicationLag()
+	switch {
+	case measuredAt.IsZero():
+		return true, "not measured yet"
+	case time.Since(measuredAt) > maxLagMeasurementAge:
+		return false, fmt.Sprintf("last measured %s ago", time.Since(measuredAt).Round(time.Second))
+	case lag > s.maxReadyLag:
+		return false, fmt.Sprintf("lag %s is over %s", lag, s.maxReadyLag)
+	}
+	return true, fmt.Sprintf("lag %s", lag)
+}
+
+// checkMigrations checks that the tables of the schema exist, until they were found.
+func (s *Store) checkMigrations(ctx context.Context) (bool, string) {
+	if atomic.LoadInt32(&s.migrated) == 1 {
+		return true, ""
+	}
+	var missing []string
+	for _, table := range requiredTables {
+		var exists bool
+		if err := s.rwDBPool.QueryRow(ctx, tableExistsQuery, table).Scan(&exists); err != nil {
+			return false, err.Error()
+		}
+		if !exists {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		return false, "missing tables: " + strings.Join(missing, ", ")
+	}
+	atomic.StoreInt32(&s.migrated, 1)
+	return true, ""
+}
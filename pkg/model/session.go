@@ -0,0 +1,144 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kong/pg-aurora-client/pkg/pool"
+	"go.uber.org/zap"
+)
+
+// ConsistencyToken is the session_token ID inserted after a session's last write.
+// A reader that has replicated this ID has also replicated the write.
+type ConsistencyToken int64
+
+// Session tokens are inserted rather than updated, so marking writes never contends
+// on a row, and use their own table so they never disturb the lag check canary.
+var (
+	markWriteQuery = `INSERT INTO session_token DEFAULT VALUES RETURNING id`
+	// IDs are not allocated in commit order, so the token itself must be visible, or
+	// already pruned, which happens only after it committed. min(id) is NULL while
+	// the replica has no token at all, the token is not replicated then.
+	sessionTokenReplicatedQuery = `SELECT COALESCE(EXISTS (SELECT 1 FROM session_token WHERE id = $1::bigint)
+     OR $1::bigint < (SELECT min(id) FROM session_token), false)`
+	pruneSessionTokenQuery = `DELETE FROM session_token
+     WHERE ts < CURRENT_TIMESTAMP - $1::interval AND id < (SELECT max(id) FROM session_token)`
+)
+
+// sessionTokenRetention is how long tokens are kept, sessions carrying older tokens
+// have long been replicated
+var sessionTokenRetention = time.Hour
+
+// Session routes reads to the writer until the replica serving them has caught up
+// with the session's writes. A Session is safe for concurrent use.
+type Session struct {
+	store *Store
+	mu    sync.Mutex
+	token ConsistencyToken
+	// replicated maps the replicas seen to replicate token, by address
+	replicated map[string]bool
+}
+
+// NewSession starts a session. Pass the token of an earlier session, e.g. one carried
+// across requests by the caller, or zero to start without pending writes.
+func (s *Store) NewSession(token ConsistencyToken) *Session {
+	return &Session{store: s, token: token}
+}
+
+// Writer returns the pool writes in this session must use. Call MarkWrite after
+// each write has committed.
+func (ss *Session) Writer() pool.PGXConnPool {
+	return ss.store.rwDBPool
+}
+
+// MarkWrite records that the session wrote and returns the session's new token.
+func (ss *Session) MarkWrite(ctx context.Context) (ConsistencyToken, error) {
+	var id int64
+	err := ss.store.rwDBPool.QueryRow(ctx, markWriteQuery).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ConsistencyToken(id) > ss.token {
+		ss.token = ConsistencyToken(id)
+		ss.replicated = nil
+	}
+	return ss.token, nil
+}
+
+// Token returns the token of the session's last write, or zero if there is none.
+func (ss *Session) Token() ConsistencyToken {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.token
+}
+
+// Reader acquires the connection reads in this session must use. It comes from
+// Store.ReadPool when the replica it is connected to has replicated the session's
+// last write, otherwise from the writer. Release the connection when done.
+func (ss *Session) Reader(ctx context.Context) (*pgxpool.Conn, error) {
+	ss.mu.Lock()
+	token := ss.token
+	ss.mu.Unlock()
+	readPool := ss.store.ReadPool(ctx)
+	if token == 0 || readPool == ss.store.rwDBPool {
+		return readPool.Acquire(ctx)
+	}
+	conn, err := readPool.Acquire(ctx)
+	if err != nil {
+		ss.store.Logger.Warn("session reader acquire failed, reading from writer", zap.Error(err))
+		return ss.store.rwDBPool.Acquire(ctx)
+	}
+	// The check runs on the connection that serves the reads, a replica behind the
+	// same endpoint may not have caught up yet
+	replica := conn.Conn().PgConn().Conn().RemoteAddr().String()
+	if ss.replicatedOn(replica, token) {
+		return conn, nil
+	}
+	var replicated bool
+	err = conn.QueryRow(ctx, sessionTokenReplicatedQuery, int64(token)).Scan(&replicated)
+	if err != nil {
+		ss.store.Logger.Warn("session token read failed, reading from writer", zap.Error(err))
+	}
+	if err != nil || !replicated {
+		conn.Release()
+		return ss.store.rwDBPool.Acquire(ctx)
+	}
+	ss.observe(replica, token)
+	return conn, nil
+}
+
+// replicatedOn reports whether replica was seen to replicate token, the session's
+// current token.
+func (ss *Session) replicatedOn(replica string, token ConsistencyToken) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.token == token && ss.replicated[replica]
+}
+
+// observe records that replica replicated token, unless the session wrote since.
+func (ss *Session) observe(replica string, token ConsistencyToken) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.token != token {
+		return
+	}
+	if ss.replicated == nil {
+		ss.replicated = make(map[string]bool)
+	}
+	ss.replicated[replica] = true
+}
+
+// pruneSessionTokens deletes the tokens older than sessionTokenRetention, keeping the
+// last one so the reader never sees the IDs go backwards.
+func (s *Store) pruneSessionTokens() {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultLagCheckFrequency)
+	defer cancel()
+	_, err := s.rwDBPool.Exec(ctx, pruneSessionTokenQuery, sessionTokenRetention.String())
+	if err != nil {
+		s.Logger.Warn("session token pruning failed", zap.Error(err))
+	}
+}
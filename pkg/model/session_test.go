@@ -0,0 +1,26 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_Replicated(t *testing.T) {
+	s := &Store{}
+	session := s.NewSession(40)
+	require.False(t, session.replicatedOn("10.0.0.1:5432", 40))
+
+	session.observe("10.0.0.1:5432", 40)
+	require.True(t, session.replicatedOn("10.0.0.1:5432", 40))
+	require.False(t, session.replicatedOn("10.0.0.2:5432", 40), "replicas are tracked separately")
+
+	session.observe("10.0.0.2:5432", 39)
+	require.False(t, session.replicatedOn("10.0.0.2:5432", 40), "an older token is ignored")
+
+	session.mu.Lock()
+	session.token, session.replicated = 41, nil
+	session.mu.Unlock()
+	require.False(t, session.replicatedOn("10.0.0.1:5432", 41), "a new write must be replicated again")
+	require.Equal(t, ConsistencyToken(41), session.Token())
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/kong/pg-aurora-client/pkg/metrics"
+	"github.com/kong/pg-aurora-client/pkg/pool"
+)
+
+// lag measurements older than this are not trusted for routing
+var maxLagMeasurementAge = defaultLagCheckFrequency * 2
+
+type maxStalenessKey struct{}
+
+// WithMaxStaleness returns a context for reads that tolerate up to maxStaleness of
+// replication lag. ReadPool routes such reads to the writer when the reader is
+// further behind or its lag is unknown.
+func WithMaxStaleness(ctx context.Context, maxStaleness time.Duration) context.Context {
+	return context.WithValue(ctx, maxStalenessKey{}, maxStaleness)
+}
+
+// MaxStalenessFromContext returns the staleness budget set by WithMaxStaleness.
+func MaxStalenessFromContext(ctx context.Context) (time.Duration, bool) {
+	maxStaleness, ok := ctx.Value(maxStalenessKey{}).(time.Duration)
+	return maxStaleness, ok
+}
+
+// ReplicationLag returns the last lag measured by the background lag check and when
+// it was measured. The time is zero if no measurement succeeded yet.
+func (s *Store) ReplicationLag() (time.Duration, time.Time) {
+	s.lagMu.RLock()
+	defer s.lagMu.RUnlock()
+	return s.replicationLag, s.lagMeasuredAt
+}
+
+// recordReplicationLag stores and emits a lag measurement in milliseconds.
+func (s *Store) recordReplicationLag(diffMS float64) {
+	metrics.Gauge("pg_aurora_custom_replication_lag", diffMS)
+	s.setReplicationLag(time.Duration(diffMS * float64(time.Millisecond)))
+}
+
+func (s *Store) setReplicationLag(lag time.Duration) {
+	s.lagMu.Lock()
+	defer s.lagMu.Unlock()
+	s.replicationLag = lag
+	s.lagMeasuredAt = time.Now()
+}
+
+// ReadPool returns the pool a read issued with ctx should use. Without a staleness
+// budget in ctx reads go to the reader.
+func (s *Store) ReadPool(ctx context.Context) pool.PGXConnPool {
+	if s.roDBPool == nil {
+		return s.rwDBPool
+	}
+	maxStaleness, ok := MaxStalenessFromContext(ctx)
+	if !ok {
+		return s.roDBPool
+	}
+	lag, measuredAt := s.ReplicationLag()
+	if measuredAt.IsZero() || time.Since(measuredAt) > maxLagMeasurementAge || lag > maxStaleness {
+		go metrics.Count("pg_aurora_custom_stale_read_fallback", 1)
+		return s.rwDBPool
+	}
+	return s.roDBPool
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kong/pg-aurora-client/pkg/metrics"
+	"github.com/kong/pg-aurora-client/pkg/pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ReadPool(t *testing.T) {
+	rw, ro := &pool.AuroraPGPool{}, &pool.AuroraPGPool{}
+	s := &Store{rwDBPool: rw, roDBPool: ro}
+	ctx := context.Background()
+	budget := WithMaxStaleness(ctx, time.Second)
+
+	require.Same(t, ro, s.ReadPool(ctx), "no budget always reads from the reader")
+	require.Same(t, rw, s.ReadPool(budget), "unknown lag falls back to the writer")
+
+	s.setReplicationLag(100 * time.Millisecond)
+	require.Same(t, ro, s.ReadPool(budget))
+
+	s.setReplicationLag(2 * time.Second)
+	require.Same(t, rw, s.ReadPool(budget))
+
+	s.lagMu.Lock()
+	s.replicationLag = 0
+	s.lagMeasuredAt = time.Now().Add(-2 * maxLagMeasurementAge)
+	s.lagMu.Unlock()
+	require.Same(t, rw, s.ReadPool(budget), "outdated measurements are not trusted")
+}
+
+func TestStore_RecordReplicationLag(t *testing.T) {
+	recorder, restore := metrics.UseRecordingClient()
+	defer restore()
+	s := &Store{}
+
+	s.recordReplicationLag(12.5)
+	lag, _ := s.ReplicationLag()
+	require.Equal(t, 12500*time.Microsecond, lag)
+	value, ok := recorder.LastValue("pg_aurora_custom_replication_lag")
+	require.True(t, ok)
+	require.Equal(t, 12.5, value)
+}
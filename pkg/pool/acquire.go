@@ -0,0 +1,68 @@
+package pool
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	defaultAcquireValidationIdleTime = time.Second * 5
+	defaultAcquireValidationTimeout  = time.Millisecond * 250
+)
+
+// AcquireValidationFunction validates a connection before it is handed out. It
+// receives a *pgx.Conn because pgxpool runs BeforeAcquire before wrapping the
+// connection, so a ValidationFunction cannot be used here.
+type AcquireValidationFunction func(ctx context.Context, conn *pgx.Conn) bool
+
+// AcquireValidationConfig enables validating idle connections when they are
+// acquired. Connections that fail are closed and another one is acquired.
+type AcquireValidationConfig struct {
+	// IdleTime is how long a connection must have been idle to be validated.
+	IdleTime time.Duration
+	// Timeout bounds every validation, independently of QueryValidationTimeout.
+	Timeout time.Duration
+	// Validator validates the connection, nil pings it.
+	Validator AcquireValidationFunction
+}
+
+func pingValidator(ctx context.Context, conn *pgx.Conn) bool {
+	return conn.Ping(ctx) == nil
+}
+
+func resolveAcquireValidationConfig(config *AcquireValidationConfig) *AcquireValidationConfig {
+	resolved := *config
+	if resolved.IdleTime <= 0 {
+		resolved.IdleTime = defaultAcquireValidationIdleTime
+	}
+	if resolved.Timeout <= 0 {
+		resolved.Timeout = defaultAcquireValidationTimeout
+	}
+	if resolved.Validator == nil {
+		resolved.Validator = pingValidator
+	}
+	return &resolved
+}
+
+// beforeAcquire validates connections that were idle for too long. Returning false
+// makes pgxpool close the connection and acquire another one.
+func (p *AuroraPGPool) beforeAcquire(ctx context.Context, conn *pgx.Conn, idle time.Duration) bool {
+	if idle < p.acquireValidation.IdleTime {
+		return true
+	}
+	tCtx, cancel := context.WithTimeout(ctx, p.acquireValidation.Timeout)
+	defer cancel()
+	if p.acquireValidation.Validator(tCtx, conn) {
+		return true
+	}
+	p.logger.Warn("Connection failed validation on acquire", zap.String("pg_host", p.host))
+	if p.metricsEmitter != nil {
+		go p.metricsEmitter(
+			Metric{"pg_aurora_custom_acquire_validation_failed_count", 1},
+			[]MetricsTag{{"pg_host", p.host}})
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAuroraPGPool_BeforeAcquire(t *testing.T) {
+	validations := 0
+	valid := true
+	p := &AuroraPGPool{
+		logger: zap.NewNop(),
+		acquireValidation: resolveAcquireValidationConfig(&AcquireValidationConfig{
+			IdleTime: time.Minute,
+			Validator: func(ctx context.Context, conn *pgx.Conn) bool {
+				_, hasDeadline := ctx.Deadline()
+				require.True(t, hasDeadline)
+				validations++
+				return valid
+			},
+		}),
+	}
+	require.Equal(t, defaultAcquireValidationTimeout, p.acquireValidation.Timeout)
+	conn := &pgx.Conn{}
+	ctx := context.Background()
+
+	require.True(t, p.beforeAcquire(ctx, conn, 0), "new connections are not validated")
+	require.True(t, p.beforeAcquire(ctx, conn, time.Second), "recently released connections are not validated")
+	require.Equal(t, 0, validations)
+
+	require.True(t, p.beforeAcquire(ctx, conn, 2*time.Minute))
+	require.Equal(t, 1, validations)
+
+	valid = false
+	require.False(t, p.beforeAcquire(ctx, conn, 2*time.Minute), "failed connections are discarded")
+}
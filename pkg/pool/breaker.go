@@ -0,0 +1,323 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen is returned without touching the database while the circuit
+// breaker of the pool is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+var (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitOpenTimeout      = time.Second * 10
+	defaultCircuitHalfOpenProbes   = 1
+)
+
+// CircuitState is the state of the circuit breaker of a pool.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every call with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen fails calls while probes check whether the database recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerConfig enables failing fast while the database is unreachable.
+// The breaker counts failover errors and acquire failures of calls, such as
+// connection errors and acquire timeouts, and failed query health checks.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that open the circuit.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing the database.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of successful probes that close the circuit.
+	// Probes run the QueryValidator, or a ping when it is not set.
+	HalfOpenProbes int
+	// OnStateChange is called after every state change.
+	OnStateChange func(from, to CircuitState)
+}
+
+type circuitBreaker struct {
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenProbes   int
+	onStateChange    func(from, to CircuitState)
+	now              func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(config *CircuitBreakerConfig, onStateChange func(from, to CircuitState)) *circuitBreaker {
+	b := &circuitBreaker{
+		failureThreshold: config.FailureThreshold,
+		openTimeout:      config.OpenTimeout,
+		halfOpenProbes:   config.HalfOpenProbes,
+		onStateChange:    onStateChange,
+		now:              time.Now,
+	}
+	if b.failureThreshold <= 0 {
+		b.failureThreshold = defaultCircuitFailureThreshold
+	}
+	if b.openTimeout <= 0 {
+		b.openTimeout = defaultCircuitOpenTimeout
+	}
+	if b.halfOpenProbes <= 0 {
+		b.halfOpenProbes = defaultCircuitHalfOpenProbes
+	}
+	return b
+}
+
+// setState must be called with mu held. It returns a function that reports the
+// change, to be called once mu is released.
+func (b *circuitBreaker) setState(to CircuitState) func() {
+	from := b.state
+	b.state = to
+	b.failures = 0
+	if to == CircuitOpen {
+		b.openedAt = b.now()
+	}
+	return func() {
+		if b.onStateChange != nil {
+			b.onStateChange(from, to)
+		}
+	}
+}
+
+func (b *circuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow reports whether a call may run. The first call after the open timeout
+// moves the circuit to half-open and must start the probes.
+func (b *circuitBreaker) allow() (allowed bool, startProbe bool) {
+	b.mu.Lock()
+	switch b.state {
+	case CircuitClosed:
+		b.mu.Unlock()
+		return true, false
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			b.mu.Unlock()
+			return false, false
+		}
+		notify := b.setState(CircuitHalfOpen)
+		b.mu.Unlock()
+		notify()
+		return false, true
+	}
+	b.mu.Unlock()
+	return false, false
+}
+
+// record counts a call or health check result while the circuit is closed.
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	if b.state != CircuitClosed {
+		b.mu.Unlock()
+		return
+	}
+	if !failed {
+		b.failures = 0
+		b.mu.Unlock()
+		return
+	}
+	b.failures++
+	if b.failures < b.failureThreshold {
+		b.mu.Unlock()
+		return
+	}
+	notify := b.setState(CircuitOpen)
+	b.mu.Unlock()
+	notify()
+}
+
+// probed closes the circuit after successful probes and opens it again otherwise.
+func (b *circuitBreaker) probed(healthy bool) {
+	b.mu.Lock()
+	if b.state != CircuitHalfOpen {
+		b.mu.Unlock()
+		return
+	}
+	to := CircuitOpen
+	if healthy {
+		to = CircuitClosed
+	}
+	notify := b.setState(to)
+	b.mu.Unlock()
+	notify()
+}
+
+// CircuitState returns the state of the circuit breaker, CircuitClosed when it is
+// not enabled.
+func (p *AuroraPGPool) CircuitState() CircuitState {
+	if p.breaker == nil {
+		return CircuitClosed
+	}
+	return p.breaker.State()
+}
+
+// allowCall fails fast with ErrPoolClosed once the pool is closed, with ErrNotReady
+// until it connected and with ErrCircuitOpen while the circuit is not closed.
+func (p *AuroraPGPool) allowCall() error {
+	if p.isClosed() {
+		return ErrPoolClosed
+	}
+	if !p.Ready() {
+		return ErrNotReady
+	}
+	if p.breaker == nil {
+		return nil
+	}
+	allowed, startProbe := p.breaker.allow()
+	if startProbe {
+		go p.probeCircuit()
+	}
+	if allowed {
+		return nil
+	}
+	if p.metricsEmitter != nil {
+		go p.metricsEmitter(
+			Metric{"pg_aurora_custom_circuit_rejected_count", 1},
+			[]MetricsTag{{"pg_host", p.host}})
+	}
+	return ErrCircuitOpen
+}
+
+// recordCall feeds the result of a call to the circuit breaker. Only errors that
+// mean the database is unreachable count as failures: failover errors and failures
+// to acquire a connection, such as connection errors and acquire timeouts.
+func (p *AuroraPGPool) recordCall(err error) {
+	if p.breaker == nil || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrNotReady) ||
+		errors.Is(err, ErrPoolClosed) {
+		return
+	}
+	if err != nil && !IsFailoverError(err) && !isAcquireFailure(err) {
+		return
+	}
+	p.breaker.record(err != nil)
+}
+
+// acquireError wraps the error of acquiring a connection, so the breaker can tell
+// an acquire timeout from a query that ran out of time.
+type acquireError struct {
+	err error
+}
+
+func (e *acquireError) Error() string { return e.err.Error() }
+func (e *acquireError) Unwrap() error { return e.err }
+
+// isAcquireFailure reports whether err failed to acquire a connection, unless the
+// caller gave up.
+func isAcquireFailure(err error) bool {
+	var acquireErr *acquireError
+	return errors.As(err, &acquireErr) && !errors.Is(err, context.Canceled)
+}
+
+// recordHealth feeds a query health check to the circuit breaker. A check where no
+// connection was available or every connection failed is a failure.
+func (p *AuroraPGPool) recordHealth(result HealthCheckResult) {
+	if p.breaker == nil {
+		return
+	}
+	p.breaker.record(result.Unavailable || (result.Validated > 0 && result.Failed == result.Validated))
+}
+
+// probeCircuit validates connections while the circuit is half-open.
+func (p *AuroraPGPool) probeCircuit() {
+	for i := 0; i < p.breaker.halfOpenProbes; i++ {
+		if !p.probe() {
+			p.breaker.probed(false)
+			return
+		}
+	}
+	p.breaker.probed(true)
+}
+
+func (p *AuroraPGPool) probe() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryValidationTimeout)
+	defer cancel()
+	conn, err := p.innerPool.Acquire(ctx)
+	if err != nil {
+		p.logger.Warn("circuit breaker probe failed to acquire", zap.Error(err))
+		return false
+	}
+	defer conn.Release()
+	if p.queryValidationFunc != nil {
+		return p.queryValidationFunc(ctx, conn, p.logger)
+	}
+	return conn.Ping(ctx) == nil
+}
+
+// circuitStateChanged logs, emits and forwards state changes.
+func (p *AuroraPGPool) circuitStateChanged(callback func(from, to CircuitState)) func(from, to CircuitState) {
+	return func(from, to CircuitState) {
+		p.logger.Warn("circuit breaker state changed", zap.String("pg_host", p.host),
+			zap.Stringer("from", from), zap.Stringer("to", to))
+		if p.metricsEmitter != nil {
+			go p.metricsEmitter(
+				Metric{"pg_aurora_custom_circuit_state_change_count", 1},
+				[]MetricsTag{{"pg_host", p.host}, {"state", to.String()}})
+		}
+		switch to {
+		case CircuitOpen:
+			p.setHealth(Unavailable, "circuit breaker open", nil)
+		case CircuitClosed:
+			p.setHealth(Healthy, "circuit breaker closed", nil)
+		}
+		if callback != nil {
+			callback(from, to)
+		}
+	}
+}
+
+// errRows and errBatchResults return the error of a call that was not run.
+type errRows struct {
+	err error
+}
+
+func (e errRows) Close()                                       {}
+func (e errRows) Err() error                                   { return e.err }
+func (e errRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (e errRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (e errRows) Next() bool                                   { return false }
+func (e errRows) Scan(...any) error                            { return e.err }
+func (e errRows) Values() ([]any, error)                       { return nil, e.err }
+func (e errRows) RawValues() [][]byte                          { return nil }
+func (e errRows) Conn() *pgx.Conn                              { return nil }
+
+type errBatchResults struct {
+	err error
+}
+
+func (e errBatchResults) Exec() (pgconn.CommandTag, error) { return pgconn.CommandTag{}, e.err }
+func (e errBatchResults) Query() (pgx.Rows, error)         { return errRows{e.err}, e.err }
+func (e errBatchResults) QueryRow() pgx.Row                { return errRows{e.err} }
+func (e errBatchResults) Close() error                     { return e.err }
@@ -0,0 +1,157 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var changes []CircuitState
+	b := newCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute},
+		func(_, to CircuitState) { changes = append(changes, to) })
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.record(true)
+	b.record(false)
+	b.record(true)
+	require.Equal(t, CircuitClosed, b.State(), "a success resets the failure count")
+	b.record(true)
+	require.Equal(t, CircuitOpen, b.State())
+
+	allowed, probe := b.allow()
+	require.False(t, allowed)
+	require.False(t, probe)
+
+	now = now.Add(time.Minute)
+	allowed, probe = b.allow()
+	require.False(t, allowed)
+	require.True(t, probe, "the first call after the timeout starts the probes")
+	_, probe = b.allow()
+	require.False(t, probe)
+
+	b.probed(false)
+	require.Equal(t, CircuitOpen, b.State())
+	now = now.Add(time.Minute)
+	b.allow()
+	b.probed(true)
+	require.Equal(t, CircuitClosed, b.State())
+	require.Equal(t, []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}, changes)
+}
+
+func TestAuroraPGPool_CircuitOpen(t *testing.T) {
+	p := &AuroraPGPool{logger: zap.NewNop()}
+	p.breaker = newCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 1}, p.circuitStateChanged(nil))
+
+	p.recordCall(&pgconn.PgError{Code: "23505"})
+	require.Equal(t, CircuitClosed, p.CircuitState(), "query errors do not open the circuit")
+	p.recordCall(io.ErrUnexpectedEOF)
+	require.Equal(t, CircuitOpen, p.CircuitState())
+
+	ctx := context.Background()
+	_, err := p.Exec(ctx, "SELECT 1")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	rows, err := p.Query(ctx, "SELECT 1")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	rows.Close()
+	require.ErrorIs(t, p.QueryRow(ctx, "SELECT 1").Scan(), ErrCircuitOpen)
+	_, err = p.Acquire(ctx)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	_, err = p.Begin(ctx)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.ErrorIs(t, p.SendBatch(ctx, nil).Close(), ErrCircuitOpen)
+	require.ErrorIs(t, p.Ping(ctx), ErrCircuitOpen)
+	require.Nil(t, p.AcquireAllIdle(ctx))
+}
+
+func TestAuroraPGPool_CircuitOpensOnHangingDial(t *testing.T) {
+	p, err := New(context.Background(), testDSN,
+		WithLazyConnect(LazyConnectConfig{InitialInterval: time.Hour, AttemptTimeout: time.Millisecond}),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour}),
+		WithPGXConfig(func(c *pgxpool.Config) {
+			c.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+		}))
+	require.NoError(t, err)
+	defer p.Close()
+	// Calls are let through as if the pool had connected before the database hung
+	atomic.StoreInt32(&p.connecting, 0)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = p.Exec(cancelled, "SELECT 1")
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, CircuitClosed, p.CircuitState(), "callers giving up do not count")
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		_, err = p.Exec(ctx, "SELECT 1")
+		cancel()
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	require.Equal(t, CircuitOpen, p.CircuitState(), "acquire timeouts open the circuit")
+	_, err = p.Exec(context.Background(), "SELECT 1")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestAuroraPGPool_CircuitCountsTxAcquireTimeoutsOnce(t *testing.T) {
+	p, err := New(context.Background(), testDSN,
+		WithLazyConnect(LazyConnectConfig{InitialInterval: time.Hour, AttemptTimeout: time.Millisecond}),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour}),
+		WithPGXConfig(func(c *pgxpool.Config) {
+			c.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+		}))
+	require.NoError(t, err)
+	defer p.Close()
+	atomic.StoreInt32(&p.connecting, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	err = p.RunInTx(ctx, pgx.TxOptions{}, func(pgx.Tx) error { return nil })
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, CircuitClosed, p.CircuitState(), "a failed transaction counts once")
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*20)
+	_, err = p.BeginTx(ctx, pgx.TxOptions{})
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, CircuitOpen, p.CircuitState(), "acquire timeouts of transactions open the circuit")
+}
+
+func TestAuroraPGPool_CircuitCountsBatchAcquireTimeouts(t *testing.T) {
+	p, err := New(context.Background(), testDSN,
+		WithLazyConnect(LazyConnectConfig{InitialInterval: time.Hour, AttemptTimeout: time.Millisecond}),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour}),
+		WithPGXConfig(func(c *pgxpool.Config) {
+			c.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+		}))
+	require.NoError(t, err)
+	defer p.Close()
+	atomic.StoreInt32(&p.connecting, 0)
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		err = p.SendBatch(ctx, &pgx.Batch{}).Close()
+		cancel()
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	require.Equal(t, CircuitOpen, p.CircuitState(), "acquire timeouts of batches open the circuit")
+}
@@ -2,10 +2,12 @@ package pool
 
 import (
 	"context"
+	"fmt"
 	"runtime/debug"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
@@ -65,6 +67,9 @@ var (
 	defaultMinAvailableConnectionFailSize = 3
 	defaultValidationCountDestroyTrigger  = 2
 	defaultQueryValidationTimeout         = time.Millisecond * 500
+	defaultHealthAcquireTimeout           = time.Millisecond * 500
+	// Intentionally not being aggressive since we have 2 background check threads
+	defaultPGXHealthCheckPeriod = time.Minute * 5
 )
 
 type Config struct {
@@ -75,4 +80,246 @@ type Config struct {
 	MinAvailableConnectionFailSize int
 	ValidationCountDestroyTrigger  int
 	MetricsEmitter                 MetricsEmitterFunction
+	// Role enables writer demotion detection when set to ReadWrite.
+	Role PoolRole
+	// TopologyCheckPeriod enables polling aurora_replica_status() for writer changes.
+	// Zero disables the check, which is required for non-Aurora PostgreSQL.
+	TopologyCheckPeriod time.Duration
+	// Retry enables retrying calls made with a WithIdempotent context on failover errors.
+	Retry *RetryConfig
+	// TxRetry configures the attempts and backoff of RunInTx, nil uses the defaults.
+	TxRetry *RetryConfig
+	// DNSRefreshInterval enables re-resolving the host and recycling connections to
+	// addresses that are no longer in the answer. Zero disables the refresh.
+	DNSRefreshInterval time.Duration
+	// LookupFunc and DialFunc replace the ones of PGXConfig.ConnConfig when set.
+	LookupFunc pgconn.LookupFunc
+	DialFunc   pgconn.DialFunc
+	// Credentials supplies the password of new connections. Connections that used a
+	// rotated password are recycled, CredentialsCheckPeriod sets how often it is reloaded.
+	Credentials            CredentialsSource
+	CredentialsCheckPeriod time.Duration
+	// InstrumentQueries emits a latency histogram and an error count for every
+	// Exec, Query, QueryRow, SendBatch and CopyFrom through MetricsEmitter.
+	InstrumentQueries bool
+	// SlowQuery enables logging slow queries. It is chained with the tracer of
+	// PGXConfig.ConnConfig.
+	SlowQuery *SlowQueryConfig
+	// HealthPolicy decides what the query health check does with the validation
+	// results. When nil, the pool is reset based on MinAvailableConnectionFailSize and
+	// ValidationCountDestroyTrigger. HealthAcquireTimeout bounds acquiring the
+	// connections to validate.
+	HealthPolicy HealthPolicy
+	// NewHealthPolicy replaces HealthPolicy for stateful policies, it is called for
+	// every pool built from the config, including the replica pools of a ReaderPool,
+	// so each pool counts its own failures.
+	NewHealthPolicy      func() HealthPolicy
+	HealthAcquireTimeout time.Duration
+	// AcquireValidation enables validating connections that were idle for a while
+	// when they are acquired, instead of only on the query health check tick.
+	AcquireValidation *AcquireValidationConfig
+	// CircuitBreaker enables failing calls fast with ErrCircuitOpen while the
+	// database is unreachable.
+	CircuitBreaker *CircuitBreakerConfig
+	// PGXHealthCheckPeriod is the HealthCheckPeriod of the pgxpool, which replaces
+	// the one of PGXConfig. Zero uses five minutes.
+	PGXHealthCheckPeriod time.Duration
+	// LazyConnect enables returning the pool before it connected, instead of
+	// failing when the database cannot be reached yet.
+	LazyConnect *LazyConnectConfig
+}
+
+// ConfigError reports an invalid Config field.
+type ConfigError struct {
+	Field  string
+	Value  interface{}
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Value == nil {
+		return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("invalid %s %v: %s", e.Field, e.Value, e.Reason)
+}
+
+func validateDuration(field string, d time.Duration) error {
+	if d < 0 {
+		return &ConfigError{field, d, "must not be negative"}
+	}
+	return nil
+}
+
+func validateCount(field string, n int) error {
+	if n < 0 {
+		return &ConfigError{field, n, "must not be negative"}
+	}
+	return nil
+}
+
+func validateRetryConfig(field string, config *RetryConfig) error {
+	if config == nil {
+		return nil
+	}
+	if err := validateCount(field+".MaxAttempts", config.MaxAttempts); err != nil {
+		return err
+	}
+	if err := validateDuration(field+".InitialInterval", config.InitialInterval); err != nil {
+		return err
+	}
+	if err := validateDuration(field+".MaxInterval", config.MaxInterval); err != nil {
+		return err
+	}
+	if config.MaxInterval > 0 && config.MaxInterval < config.InitialInterval {
+		return &ConfigError{field + ".MaxInterval", config.MaxInterval, "must not be lower than InitialInterval"}
+	}
+	return nil
+}
+
+// Validate returns a *ConfigError for the first invalid field of the config. Zero
+// values are valid and replaced by defaults.
+func (c *Config) Validate() error {
+	if c.PGXConfig == nil || c.PGXConfig.ConnConfig == nil {
+		return &ConfigError{Field: "PGXConfig", Reason: "is required"}
+	}
+	if c.Role != "" && c.Role != ReadWrite && c.Role != ReadOnly {
+		return &ConfigError{"Role", c.Role, fmt.Sprintf("must be %q or %q", ReadWrite, ReadOnly)}
+	}
+	if c.HealthPolicy != nil && c.NewHealthPolicy != nil {
+		return &ConfigError{Field: "NewHealthPolicy", Reason: "must not be set with HealthPolicy"}
+	}
+	durations := []struct {
+		field string
+		value time.Duration
+	}{
+		{"QueryValidationTimeout", c.QueryValidationTimeout},
+		{"QueryHealthCheckPeriod", c.QueryHealthCheckPeriod},
+		{"TopologyCheckPeriod", c.TopologyCheckPeriod},
+		{"DNSRefreshInterval", c.DNSRefreshInterval},
+		{"CredentialsCheckPeriod", c.CredentialsCheckPeriod},
+		{"HealthAcquireTimeout", c.HealthAcquireTimeout},
+		{"PGXHealthCheckPeriod", c.PGXHealthCheckPeriod},
+	}
+	for _, d := range durations {
+		if err := validateDuration(d.field, d.value); err != nil {
+			return err
+		}
+	}
+	if err := validateCount("MinAvailableConnectionFailSize", c.MinAvailableConnectionFailSize); err != nil {
+		return err
+	}
+	if err := validateCount("ValidationCountDestroyTrigger", c.ValidationCountDestroyTrigger); err != nil {
+		return err
+	}
+	if err := validateRetryConfig("Retry", c.Retry); err != nil {
+		return err
+	}
+	if err := validateRetryConfig("TxRetry", c.TxRetry); err != nil {
+		return err
+	}
+	if c.SlowQuery != nil {
+		if err := validateDuration("SlowQuery.Threshold", c.SlowQuery.Threshold); err != nil {
+			return err
+		}
+		if c.SlowQuery.SampleRate < 0 || c.SlowQuery.SampleRate > 1 {
+			return &ConfigError{"SlowQuery.SampleRate", c.SlowQuery.SampleRate, "must be between 0 and 1"}
+		}
+	}
+	if c.AcquireValidation != nil {
+		if err := validateDuration("AcquireValidation.IdleTime", c.AcquireValidation.IdleTime); err != nil {
+			return err
+		}
+		if err := validateDuration("AcquireValidation.Timeout", c.AcquireValidation.Timeout); err != nil {
+			return err
+		}
+	}
+	if c.LazyConnect != nil {
+		if err := validateDuration("LazyConnect.InitialInterval", c.LazyConnect.InitialInterval); err != nil {
+			return err
+		}
+		if err := validateDuration("LazyConnect.MaxInterval", c.LazyConnect.MaxInterval); err != nil {
+			return err
+		}
+		if err := validateDuration("LazyConnect.AttemptTimeout", c.LazyConnect.AttemptTimeout); err != nil {
+			return err
+		}
+	}
+	if c.CircuitBreaker != nil {
+		if err := validateCount("CircuitBreaker.FailureThreshold", c.CircuitBreaker.FailureThreshold); err != nil {
+			return err
+		}
+		if err := validateDuration("CircuitBreaker.OpenTimeout", c.CircuitBreaker.OpenTimeout); err != nil {
+			return err
+		}
+		if err := validateCount("CircuitBreaker.HalfOpenProbes", c.CircuitBreaker.HalfOpenProbes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve returns a copy of the config with the defaults filled in. The copy
+// shares nothing the pool modifies with c.
+func (c *Config) resolve() Config {
+	r := *c
+	r.PGXConfig = c.PGXConfig.Copy()
+	if r.QueryValidationTimeout == 0 {
+		r.QueryValidationTimeout = defaultQueryValidationTimeout
+	}
+	if r.QueryHealthCheckPeriod == 0 {
+		r.QueryHealthCheckPeriod = defaultQueryHealthCheckPeriod
+	}
+	if r.MinAvailableConnectionFailSize == 0 {
+		r.MinAvailableConnectionFailSize = defaultMinAvailableConnectionFailSize
+	}
+	if r.ValidationCountDestroyTrigger == 0 {
+		r.ValidationCountDestroyTrigger = defaultValidationCountDestroyTrigger
+	}
+	if r.HealthPolicy == nil && r.NewHealthPolicy == nil {
+		r.HealthPolicy = thresholdHealthPolicy{r.MinAvailableConnectionFailSize, r.ValidationCountDestroyTrigger}
+	}
+	if r.HealthAcquireTimeout == 0 {
+		r.HealthAcquireTimeout = defaultHealthAcquireTimeout
+	}
+	if r.CredentialsCheckPeriod == 0 {
+		r.CredentialsCheckPeriod = defaultCredentialsCheckPeriod
+	}
+	if r.PGXHealthCheckPeriod == 0 {
+		r.PGXHealthCheckPeriod = defaultPGXHealthCheckPeriod
+	}
+	r.PGXConfig.HealthCheckPeriod = r.PGXHealthCheckPeriod
+	if r.LookupFunc != nil {
+		r.PGXConfig.ConnConfig.LookupFunc = r.LookupFunc
+	}
+	if r.DialFunc != nil {
+		r.PGXConfig.ConnConfig.DialFunc = r.DialFunc
+	}
+	if c.Retry != nil {
+		r.Retry = resolveRetryConfig(c.Retry)
+	}
+	r.TxRetry = resolveRetryConfig(c.TxRetry)
+	if c.AcquireValidation != nil {
+		r.AcquireValidation = resolveAcquireValidationConfig(c.AcquireValidation)
+	}
+	if c.LazyConnect != nil {
+		r.LazyConnect = resolveLazyConnectConfig(c.LazyConnect)
+	}
+	if c.SlowQuery != nil {
+		slowQuery := *c.SlowQuery
+		r.SlowQuery = &slowQuery
+	}
+	if c.CircuitBreaker != nil {
+		circuitBreaker := *c.CircuitBreaker
+		if circuitBreaker.FailureThreshold == 0 {
+			circuitBreaker.FailureThreshold = defaultCircuitFailureThreshold
+		}
+		if circuitBreaker.OpenTimeout == 0 {
+			circuitBreaker.OpenTimeout = defaultCircuitOpenTimeout
+		}
+		if circuitBreaker.HalfOpenProbes == 0 {
+			circuitBreaker.HalfOpenProbes = defaultCircuitHalfOpenProbes
+		}
+		r.CircuitBreaker = &circuitBreaker
+	}
+	return r
 }
@@ -0,0 +1,121 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.uber.org/zap"
+)
+
+// ErrNotReady is returned without touching the database while a lazily connected
+// pool has not connected yet.
+var ErrNotReady = errors.New("pool is not connected yet")
+
+var (
+	defaultLazyConnectInitialInterval = time.Millisecond * 500
+	defaultLazyConnectMaxInterval     = time.Second * 30
+	defaultLazyConnectAttemptTimeout  = time.Second * 5
+)
+
+// LazyConnectConfig enables returning the pool before it connected. The initial
+// connection is retried in the background with backoff until it succeeds or the
+// pool is closed, calls fail with ErrNotReady meanwhile and Health reports
+// Connecting.
+type LazyConnectConfig struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// AttemptTimeout bounds every connection attempt.
+	AttemptTimeout time.Duration
+}
+
+func resolveLazyConnectConfig(config *LazyConnectConfig) *LazyConnectConfig {
+	resolved := *config
+	if resolved.InitialInterval <= 0 {
+		resolved.InitialInterval = defaultLazyConnectInitialInterval
+	}
+	if resolved.MaxInterval <= 0 {
+		resolved.MaxInterval = defaultLazyConnectMaxInterval
+	}
+	if resolved.AttemptTimeout <= 0 {
+		resolved.AttemptTimeout = defaultLazyConnectAttemptTimeout
+	}
+	return &resolved
+}
+
+// Ready reports whether the pool connected. It is false only while a lazily
+// connected pool has not connected yet.
+func (p *AuroraPGPool) Ready() bool {
+	return atomic.LoadInt32(&p.connecting) == 0
+}
+
+// connect checks that the database accepts connections.
+func (p *AuroraPGPool) connect(ctx context.Context) error {
+	err := p.innerPool.Ping(ctx)
+	if err != nil && p.credentials != nil && isAuthError(err) {
+		// The password may have been rotated since it was first read
+		p.reloadCredentials()
+		err = p.innerPool.Ping(ctx)
+	}
+	return err
+}
+
+// backgroundConnect retries the initial connection until it succeeds or the pool
+// is closed.
+func (p *AuroraPGPool) backgroundConnect() {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = p.lazyConnect.InitialInterval
+	b.MaxInterval = p.lazyConnect.MaxInterval
+	b.MaxElapsedTime = 0
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), p.lazyConnect.AttemptTimeout)
+		err := p.connect(ctx)
+		cancel()
+		if err == nil {
+			p.logger.Info("pool connected", zap.String("pg_host", p.host), zap.Int("attempt", attempt))
+			p.start()
+			return
+		}
+		p.logger.Warn("pool connection failed, retrying", zap.String("pg_host", p.host),
+			zap.Int("attempt", attempt), zap.Error(err))
+		p.setHealth(Connecting, "connecting: "+err.Error(), nil)
+		if p.metricsEmitter != nil {
+			go p.metricsEmitter(
+				Metric{"pg_aurora_custom_connect_retry_count", 1},
+				[]MetricsTag{{"pg_host", p.host}})
+		}
+		select {
+		case <-p.closeChan:
+			p.logger.Info("backgroundConnect exited..")
+			return
+		case <-time.After(b.NextBackOff()):
+		}
+	}
+}
+
+// start marks the pool ready and starts its background checks.
+func (p *AuroraPGPool) start() {
+	select {
+	case <-p.closeChan:
+		return
+	default:
+	}
+	atomic.StoreInt32(&p.connecting, 0)
+	p.setHealth(Healthy, "connected", nil)
+	if p.queryValidationFunc != nil {
+		go p.backgroundQueryHealthCheck()
+	}
+	if p.topologyCheckPeriod > 0 {
+		p.checkTopology()
+		go p.backgroundTopologyCheck()
+	}
+	if p.dnsRefreshInterval > 0 {
+		p.resolveHost(p.host)
+		go p.backgroundDNSRefresh()
+	}
+	if p.credentials != nil {
+		go p.backgroundCredentialsCheck()
+	}
+}
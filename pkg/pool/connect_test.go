@@ -0,0 +1,51 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_LazyConnect(t *testing.T) {
+	var dials int32
+	refused := errors.New("connection refused")
+	p, err := New(context.Background(), testDSN,
+		WithLazyConnect(LazyConnectConfig{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond}),
+		WithPGXConfig(func(c *pgxpool.Config) {
+			c.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				atomic.AddInt32(&dials, 1)
+				return nil, refused
+			}
+		}))
+	require.NoError(t, err, "the pool is returned before it connected")
+	defer p.Close()
+
+	require.False(t, p.Ready())
+	require.Equal(t, Connecting, p.Health().State)
+	_, err = p.Exec(context.Background(), "SELECT 1")
+	require.ErrorIs(t, err, ErrNotReady)
+	require.ErrorIs(t, p.Ping(context.Background()), ErrNotReady)
+	_, err = p.Acquire(context.Background())
+	require.ErrorIs(t, err, ErrNotReady)
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&dials) > 2 }, time.Second, time.Millisecond,
+		"the connection is retried in the background")
+	require.Equal(t, Connecting, p.Health().State)
+	require.Contains(t, p.Health().Reason, "connecting: ")
+}
+
+func TestNew_EagerConnectFails(t *testing.T) {
+	_, err := New(context.Background(), testDSN,
+		WithPGXConfig(func(c *pgxpool.Config) {
+			c.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return nil, errors.New("connection refused")
+			}
+		}))
+	require.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// connTracker follows the connections of the pool: which are acquired, so the
+// queries still running at shutdown can be cancelled, and since when the others are
+// idle, so they are validated on acquire. BeforeAcquire and AfterRelease update it
+// in the goroutine that owns the connection. pgxpool has no hook for the
+// connections it destroys otherwise, those are dropped once closed.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[*pgx.Conn]*trackedConn
+	// maxConns is the pool size, more entries mean some connections were destroyed
+	maxConns int
+}
+
+type trackedConn struct {
+	// done is the cleanupDone channel of the connection
+	done     <-chan struct{}
+	acquired bool
+	// released is when the connection was returned to the pool, zero if never
+	released time.Time
+}
+
+// cleanupDone returns the channel pgconn closes once conn is closed, it is set on
+// connect and is safe to wait on from any goroutine. A connection that never
+// connected has none.
+func cleanupDone(conn *pgx.Conn) <-chan struct{} {
+	if pgConn := conn.PgConn(); pgConn != nil {
+		return pgConn.CleanupDone()
+	}
+	return nil
+}
+
+// acquire marks conn acquired and returns how long it was idle. New connections
+// were never idle.
+func (t *connTracker) acquire(conn *pgx.Conn) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns == nil {
+		t.conns = map[*pgx.Conn]*trackedConn{}
+	}
+	tracked, ok := t.conns[conn]
+	if !ok {
+		tracked = &trackedConn{done: cleanupDone(conn)}
+		t.conns[conn] = tracked
+		if len(t.conns) > t.maxConns {
+			t.prune()
+		}
+	}
+	tracked.acquired = true
+	if tracked.released.IsZero() {
+		return 0
+	}
+	return time.Since(tracked.released)
+}
+
+// release marks conn idle in the pool.
+func (t *connTracker) release(conn *pgx.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tracked, ok := t.conns[conn]; ok {
+		tracked.acquired = false
+		tracked.released = time.Now()
+	}
+}
+
+// remove forgets conn, which the pool destroys.
+func (t *connTracker) remove(conn *pgx.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, conn)
+}
+
+// prune drops the connections pgxpool destroyed without a hook.
+func (t *connTracker) prune() {
+	for conn, tracked := range t.conns {
+		select {
+		case <-tracked.done:
+			delete(t.conns, conn)
+		default:
+		}
+	}
+}
+
+// inFlight returns the acquired connections that are not closed.
+func (t *connTracker) inFlight() []*pgx.Conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune()
+	var conns []*pgx.Conn
+	for conn, tracked := range t.conns {
+		if tracked.acquired {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+// trackAcquire runs the BeforeAcquire hooks and tracks the connections handed out.
+func (p *AuroraPGPool) trackAcquire(beforeAcquire func(context.Context, *pgx.Conn) bool) func(context.Context, *pgx.Conn) bool {
+	return func(ctx context.Context, conn *pgx.Conn) bool {
+		idle := p.conns.acquire(conn)
+		if (beforeAcquire != nil && !beforeAcquire(ctx, conn)) ||
+			(p.acquireValidation != nil && !p.beforeAcquire(ctx, conn, idle)) {
+			p.conns.remove(conn)
+			return false
+		}
+		return true
+	}
+}
+
+// trackRelease runs the AfterRelease hooks and tracks the connections returned to
+// the pool or destroyed.
+func (p *AuroraPGPool) trackRelease(afterRelease func(*pgx.Conn) bool) func(*pgx.Conn) bool {
+	return func(conn *pgx.Conn) bool {
+		if !afterRelease(conn) {
+			p.conns.remove(conn)
+			return false
+		}
+		p.conns.release(conn)
+		return true
+	}
+}
@@ -0,0 +1,30 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnTracker(t *testing.T) {
+	tracker := connTracker{maxConns: 2}
+	released, inFlight, destroyed := &pgx.Conn{}, &pgx.Conn{}, &pgx.Conn{}
+	require.Zero(t, tracker.acquire(released), "new connections were never idle")
+	tracker.acquire(inFlight)
+	tracker.release(released)
+	require.Equal(t, []*pgx.Conn{inFlight}, tracker.inFlight())
+
+	tracker.conns[released].released = time.Now().Add(-time.Minute)
+	require.GreaterOrEqual(t, tracker.acquire(released), time.Minute)
+	tracker.remove(released)
+	require.Zero(t, tracker.acquire(released), "removed connections are forgotten")
+	tracker.remove(released)
+
+	closed := make(chan struct{})
+	close(closed)
+	tracker.conns[destroyed] = &trackedConn{done: closed, acquired: true}
+	require.Equal(t, []*pgx.Conn{inFlight}, tracker.inFlight(), "destroyed connections are dropped")
+	require.Len(t, tracker.conns, 1)
+}
@@ -0,0 +1,170 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	defaultCredentialsCheckPeriod = time.Second * 30
+	minCredentialsReloadInterval  = time.Second * 5
+)
+
+// CredentialsSource supplies the password of new connections and allows it to
+// change while the pool is running.
+type CredentialsSource interface {
+	// Password returns the current password. It is called for every new connection.
+	Password(ctx context.Context) (string, error)
+	// Reload re-reads the password, e.g. after an authentication failure.
+	Reload(ctx context.Context) error
+}
+
+// CredentialsFunc adapts a callback that returns the current password.
+type CredentialsFunc func(ctx context.Context) (string, error)
+
+func (f CredentialsFunc) Password(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+func (f CredentialsFunc) Reload(context.Context) error {
+	return nil
+}
+
+// FileCredentials reads the password from a file, such as a mounted secret that is
+// rotated in place.
+type FileCredentials struct {
+	path     string
+	mu       sync.RWMutex
+	password string
+}
+
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{path: path}
+}
+
+func (f *FileCredentials) Password(ctx context.Context) (string, error) {
+	f.mu.RLock()
+	password := f.password
+	f.mu.RUnlock()
+	if password != "" {
+		return password, nil
+	}
+	if err := f.Reload(ctx); err != nil {
+		return "", err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.password, nil
+}
+
+func (f *FileCredentials) Reload(context.Context) error {
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	password := strings.TrimSpace(string(content))
+	if password == "" {
+		return errors.New("credentials file " + f.path + " is empty")
+	}
+	f.mu.Lock()
+	f.password = password
+	f.mu.Unlock()
+	return nil
+}
+
+func (p *AuroraPGPool) backgroundCredentialsCheck() {
+	ticker := time.NewTicker(p.credentialsCheckPeriod)
+	defer ticker.Stop()
+	p.loops.beat("credentials check", p.credentialsCheckPeriod)
+	for {
+		select {
+		case <-p.closeChan:
+			p.logger.Info("backgroundCredentialsCheck exited..")
+			return
+		case <-ticker.C:
+			p.reloadCredentials()
+			p.loops.beat("credentials check", p.credentialsCheckPeriod)
+		}
+	}
+}
+
+// beforeConnect sets the password of a new connection from the credentials source.
+func (p *AuroraPGPool) beforeConnect(ctx context.Context, cc *pgx.ConnConfig) error {
+	password, err := p.credentials.Password(ctx)
+	if err != nil {
+		return err
+	}
+	p.credMu.Lock()
+	p.password = password
+	p.credMu.Unlock()
+	cc.Password = password
+	return nil
+}
+
+// hasStalePassword reports whether conn authenticated with a rotated password.
+func (p *AuroraPGPool) hasStalePassword(conn *pgx.Conn) bool {
+	p.credMu.RLock()
+	defer p.credMu.RUnlock()
+	return p.password != "" && conn.Config().Password != p.password
+}
+
+// reloadCredentials re-reads the credentials and, when the password changed, closes
+// the idle connections that used the old one. Acquired connections are closed when
+// released.
+func (p *AuroraPGPool) reloadCredentials() {
+	p.credMu.Lock()
+	if time.Since(p.lastCredentialsReload) < minCredentialsReloadInterval {
+		p.credMu.Unlock()
+		return
+	}
+	p.lastCredentialsReload = time.Now()
+	p.credMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryValidationTimeout)
+	defer cancel()
+	if err := p.credentials.Reload(ctx); err != nil {
+		p.logger.Error("failed to reload credentials", zap.Error(err))
+		return
+	}
+	password, err := p.credentials.Password(ctx)
+	if err != nil {
+		p.logger.Error("failed to read credentials", zap.Error(err))
+		return
+	}
+	p.credMu.Lock()
+	changed := p.password != password
+	p.password = password
+	p.credMu.Unlock()
+	if !changed || p.innerPool == nil {
+		return
+	}
+
+	host := p.Config().ConnConfig.Host
+	recycled := 0
+	for _, conn := range p.innerPool.AcquireAllIdle(ctx) {
+		if p.hasStalePassword(conn.Conn()) {
+			if err := conn.Conn().Close(ctx); err != nil {
+				p.logger.Warn("Rotated connection close operation resulted in error", zap.Error(err))
+			}
+			recycled++
+		}
+		conn.Release()
+	}
+	p.logger.Info("Credentials rotated", zap.String("pg_host", host), zap.Int("recycled", recycled))
+	if p.metricsEmitter != nil {
+		go p.metricsEmitter(
+			Metric{"pg_aurora_custom_credentials_rotation_count", 1},
+			[]MetricsTag{{"pg_host", host}})
+	}
+}
+
+func isAuthError(err error) bool {
+	return sqlState(err) == "28P01" // invalid_password
+}
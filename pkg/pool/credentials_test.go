@@ -0,0 +1,59 @@
+package pool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+	ctx := context.Background()
+
+	creds := NewFileCredentials(path)
+	password, err := creds.Password(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "first", password)
+
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	password, err = creds.Password(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "first", password, "the password is cached until reloaded")
+
+	require.NoError(t, creds.Reload(ctx))
+	password, err = creds.Password(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "second", password)
+
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	require.Error(t, creds.Reload(ctx))
+	password, err = creds.Password(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "second", password, "a failed reload keeps the last password")
+}
+
+func TestAuroraPGPool_ReloadCredentials(t *testing.T) {
+	password := "first"
+	p := &AuroraPGPool{
+		logger:                 zap.NewNop(),
+		queryValidationTimeout: defaultQueryValidationTimeout,
+		credentials: CredentialsFunc(func(context.Context) (string, error) {
+			return password, nil
+		}),
+	}
+	p.reloadCredentials()
+	require.Equal(t, "first", p.password)
+
+	password = "second"
+	p.reloadCredentials()
+	require.Equal(t, "first", p.password, "reloads are rate limited")
+
+	p.lastCredentialsReload = p.lastCredentialsReload.Add(-minCredentialsReloadInterval)
+	p.reloadCredentials()
+	require.Equal(t, "second", p.password)
+}
@@ -0,0 +1,74 @@
+package pool
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// PoolRole tells the pool whether it is expected to accept writes.
+type PoolRole string
+
+const (
+	ReadWrite PoolRole = "rw"
+	ReadOnly  PoolRole = "ro"
+)
+
+var inRecoveryQuery = `SELECT pg_is_in_recovery()`
+
+// observeError inspects the error of every call for signs that the writer was
+// demoted or the credentials were rotated, and feeds the circuit breaker.
+func (p *AuroraPGPool) observeError(err error) {
+	p.recordCall(err)
+	if err == nil {
+		return
+	}
+	if p.role == ReadWrite && sqlState(err) == "25006" {
+		p.handleDemotion("read_only_sql_transaction")
+	}
+	if p.credentials != nil && isAuthError(err) {
+		go p.reloadCredentials()
+	}
+}
+
+// isReadOnlyTxError reports whether err is a write rejected by a transaction the
+// caller started read-only, which says nothing about the instance being demoted.
+func isReadOnlyTxError(txOptions pgx.TxOptions, err error) bool {
+	return txOptions.AccessMode == pgx.ReadOnly && sqlState(err) == "25006"
+}
+
+// checkWriterRecovery resets the pool when a writer pool is connected to a reader.
+func (p *AuroraPGPool) checkWriterRecovery(ctx context.Context) {
+	tCtx, tCancel := context.WithTimeout(ctx, p.queryValidationTimeout)
+	defer tCancel()
+	var inRecovery bool
+	err := p.innerPool.QueryRow(tCtx, inRecoveryQuery).Scan(&inRecovery)
+	if err != nil {
+		p.logger.Warn("writer recovery check failed", zap.Error(err))
+		return
+	}
+	if inRecovery {
+		p.handleDemotion("pg_is_in_recovery")
+	}
+}
+
+func (p *AuroraPGPool) handleDemotion(reason string) {
+	if !p.allowFailoverReset() {
+		return
+	}
+	host := p.Config().ConnConfig.Host
+	p.logger.Warn("Writer pool is connected to a read-only instance, resetting pool",
+		zap.String("pg_host", host), zap.String("reason", reason))
+	p.resetPool("writer demoted: " + reason)
+	if p.dnsRefreshInterval > 0 {
+		// New connections look the host up again, the answer is recorded so the
+		// connections released to the demoted address are recycled
+		p.resolveHost(host)
+	}
+	if p.metricsEmitter != nil {
+		go p.metricsEmitter(
+			Metric{"pg_aurora_custom_writer_demotion_count", 1},
+			[]MetricsTag{{"pg_host", host}, {"reason", reason}})
+	}
+}
@@ -0,0 +1,103 @@
+package pool
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+func (p *AuroraPGPool) backgroundDNSRefresh() {
+	ticker := time.NewTicker(p.dnsRefreshInterval)
+	defer ticker.Stop()
+	p.loops.beat("DNS refresh", p.dnsRefreshInterval)
+	for {
+		select {
+		case <-p.closeChan:
+			p.logger.Info("backgroundDNSRefresh exited..")
+			return
+		case <-ticker.C:
+			p.refreshDNS()
+			p.loops.beat("DNS refresh", p.dnsRefreshInterval)
+		}
+	}
+}
+
+// resolveHost looks up host and records the answer used to find connections to
+// stale addresses. It returns false when the host could not be resolved.
+func (p *AuroraPGPool) resolveHost(host string) bool {
+	if net.ParseIP(host) != nil || isUnixSocket(host) {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryValidationTimeout)
+	defer cancel()
+	addrs, err := p.lookupFunc(ctx, host)
+	if err != nil {
+		p.logger.Warn("failed to resolve host", zap.String("pg_host", host), zap.Error(err))
+		return false
+	}
+	if len(addrs) == 0 {
+		return false
+	}
+	resolved := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		resolved[addr] = true
+	}
+	p.dnsMu.Lock()
+	p.resolvedAddrs = resolved
+	p.dnsMu.Unlock()
+	p.logger.Debug("resolved host", zap.String("pg_host", host), zap.Strings("addrs", addrs))
+	return true
+}
+
+func isUnixSocket(host string) bool {
+	return len(host) > 0 && host[0] == '/'
+}
+
+// isStaleAddr reports whether addr is missing from the last DNS answer.
+func (p *AuroraPGPool) isStaleAddr(addr string) bool {
+	p.dnsMu.RLock()
+	defer p.dnsMu.RUnlock()
+	return len(p.resolvedAddrs) > 0 && !p.resolvedAddrs[addr]
+}
+
+func (p *AuroraPGPool) isStaleConn(conn *pgx.Conn) bool {
+	addr, ok := conn.PgConn().Conn().RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	return p.isStaleAddr(addr.IP.String())
+}
+
+// refreshDNS re-resolves the host and closes idle connections to addresses that are
+// no longer in the answer. Acquired connections are closed when released.
+func (p *AuroraPGPool) refreshDNS() {
+	host := p.Config().ConnConfig.Host
+	if !p.resolveHost(host) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryValidationTimeout)
+	defer cancel()
+	recycled := 0
+	for _, conn := range p.innerPool.AcquireAllIdle(ctx) {
+		if p.isStaleConn(conn.Conn()) {
+			if err := conn.Conn().Close(ctx); err != nil {
+				p.logger.Warn("Stale connection close operation resulted in error", zap.Error(err))
+			}
+			recycled++
+		}
+		conn.Release()
+	}
+	if recycled == 0 {
+		return
+	}
+	p.logger.Info("Recycled connections to stale addresses", zap.String("pg_host", host),
+		zap.Int("recycled", recycled))
+	if p.metricsEmitter != nil {
+		go p.metricsEmitter(
+			Metric{"pg_aurora_custom_stale_conn_recycled_count", float64(recycled)},
+			[]MetricsTag{{"pg_host", host}})
+	}
+}
@@ -0,0 +1,47 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeResolver struct {
+	addrs []string
+	err   error
+}
+
+func (r *fakeResolver) LookupHost(context.Context, string) ([]string, error) {
+	return r.addrs, r.err
+}
+
+func TestAuroraPGPool_ResolveHost(t *testing.T) {
+	resolver := &fakeResolver{addrs: []string{"10.0.0.1", "10.0.0.2"}}
+	p := &AuroraPGPool{
+		logger:                 zap.NewNop(),
+		lookupFunc:             resolver.LookupHost,
+		queryValidationTimeout: defaultQueryValidationTimeout,
+	}
+	require.False(t, p.isStaleAddr("10.0.0.3"), "nothing is stale before the first answer")
+
+	require.True(t, p.resolveHost("koko.cluster-abc.us-west-2.rds.amazonaws.com"))
+	require.False(t, p.isStaleAddr("10.0.0.1"))
+	require.True(t, p.isStaleAddr("10.0.0.3"))
+
+	// failover moved the endpoint
+	resolver.addrs = []string{"10.0.0.3"}
+	require.True(t, p.resolveHost("koko.cluster-abc.us-west-2.rds.amazonaws.com"))
+	require.True(t, p.isStaleAddr("10.0.0.1"))
+	require.False(t, p.isStaleAddr("10.0.0.3"))
+
+	// failed lookups keep the last answer
+	resolver.err = errors.New("no such host")
+	require.False(t, p.resolveHost("koko.cluster-abc.us-west-2.rds.amazonaws.com"))
+	require.False(t, p.isStaleAddr("10.0.0.3"))
+
+	require.False(t, p.resolveHost("10.0.0.9"), "IP literals are not resolved")
+	require.False(t, p.resolveHost("/var/run/postgresql"), "unix sockets are not resolved")
+}
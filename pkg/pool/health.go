@@ -0,0 +1,126 @@
+package pool
+
+import (
+	"sync"
+)
+
+// HealthAction is what the query health check does after validating connections.
+type HealthAction int
+
+const (
+	// HealthNoop returns every connection to the pool.
+	HealthNoop HealthAction = iota
+	// HealthDestroyFailed closes the connections that failed validation.
+	HealthDestroyFailed
+	// HealthReset closes the failed connections and resets the pool.
+	HealthReset
+	// HealthEscalate resets the pool and reports that resets are not healing it.
+	HealthEscalate
+)
+
+func (a HealthAction) String() string {
+	switch a {
+	case HealthNoop:
+		return "noop"
+	case HealthDestroyFailed:
+		return "destroy-failed"
+	case HealthReset:
+		return "reset"
+	case HealthEscalate:
+		return "escalate"
+	}
+	return "unknown"
+}
+
+// HealthCheckResult is the outcome of validating the idle connections of a pool.
+type HealthCheckResult struct {
+	// Validated is the number of connections that were validated.
+	Validated int
+	// Failed is the number of connections that failed validation.
+	Failed int
+	// Unavailable is set when no connection could be acquired for validation.
+	Unavailable bool
+	MaxConns    int32
+}
+
+// HealthPolicy decides what a query health check does with its results. It is
+// called from a single goroutine per pool.
+type HealthPolicy interface {
+	Decide(result HealthCheckResult) HealthAction
+}
+
+// HealthPolicyFunc adapts a function to a HealthPolicy.
+type HealthPolicyFunc func(result HealthCheckResult) HealthAction
+
+func (f HealthPolicyFunc) Decide(result HealthCheckResult) HealthAction {
+	return f(result)
+}
+
+// thresholdHealthPolicy resets the pool when more than validationCountDestroyTrigger
+// connections failed out of more than minAvailableConnectionFailSize validated.
+// It is the policy used when Config.HealthPolicy is not set.
+type thresholdHealthPolicy struct {
+	minAvailableConnectionFailSize int
+	validationCountDestroyTrigger  int
+}
+
+func (t thresholdHealthPolicy) Decide(result HealthCheckResult) HealthAction {
+	if result.Validated > t.minAvailableConnectionFailSize && result.Failed > t.validationCountDestroyTrigger {
+		return HealthReset
+	}
+	if result.Failed > 0 {
+		return HealthDestroyFailed
+	}
+	return HealthNoop
+}
+
+// RatioHealthPolicy resets the pool when at least ResetRatio of the validated
+// connections failed, which works for pools of any size.
+type RatioHealthPolicy struct {
+	ResetRatio float64
+	// MinValidated is the number of connections that must be validated before the
+	// ratio is trusted. Zero trusts any number.
+	MinValidated int
+}
+
+func (r RatioHealthPolicy) Decide(result HealthCheckResult) HealthAction {
+	if result.Failed == 0 {
+		return HealthNoop
+	}
+	if result.Validated >= r.MinValidated &&
+		float64(result.Failed)/float64(result.Validated) >= r.ResetRatio {
+		return HealthReset
+	}
+	return HealthDestroyFailed
+}
+
+// ConsecutiveFailureHealthPolicy resets the pool after ResetAfter consecutive checks
+// with failed or unavailable connections and escalates after EscalateAfter. Use one
+// per pool.
+type ConsecutiveFailureHealthPolicy struct {
+	ResetAfter    int
+	EscalateAfter int
+	mu            sync.Mutex
+	failures      int
+}
+
+func NewConsecutiveFailureHealthPolicy(resetAfter, escalateAfter int) *ConsecutiveFailureHealthPolicy {
+	return &ConsecutiveFailureHealthPolicy{ResetAfter: resetAfter, EscalateAfter: escalateAfter}
+}
+
+func (c *ConsecutiveFailureHealthPolicy) Decide(result HealthCheckResult) HealthAction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if result.Failed == 0 && !result.Unavailable {
+		c.failures = 0
+		return HealthNoop
+	}
+	c.failures++
+	switch {
+	case c.EscalateAfter > 0 && c.failures >= c.EscalateAfter:
+		return HealthEscalate
+	case c.ResetAfter > 0 && c.failures >= c.ResetAfter:
+		return HealthReset
+	}
+	return HealthDestroyFailed
+}
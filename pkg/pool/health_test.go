@@ -0,0 +1,35 @@
+package pool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholdHealthPolicy(t *testing.T) {
+	policy := thresholdHealthPolicy{defaultMinAvailableConnectionFailSize, defaultValidationCountDestroyTrigger}
+	require.Equal(t, HealthNoop, policy.Decide(HealthCheckResult{Validated: 10}))
+	require.Equal(t, HealthDestroyFailed, policy.Decide(HealthCheckResult{Validated: 10, Failed: 2}))
+	require.Equal(t, HealthReset, policy.Decide(HealthCheckResult{Validated: 10, Failed: 3}))
+	require.Equal(t, HealthDestroyFailed, policy.Decide(HealthCheckResult{Validated: 3, Failed: 3}),
+		"small pools never reach the thresholds")
+}
+
+func TestRatioHealthPolicy(t *testing.T) {
+	policy := RatioHealthPolicy{ResetRatio: 0.5, MinValidated: 2}
+	require.Equal(t, HealthNoop, policy.Decide(HealthCheckResult{Validated: 5}))
+	require.Equal(t, HealthDestroyFailed, policy.Decide(HealthCheckResult{Validated: 5, Failed: 2}))
+	require.Equal(t, HealthReset, policy.Decide(HealthCheckResult{Validated: 5, Failed: 3}))
+	require.Equal(t, HealthDestroyFailed, policy.Decide(HealthCheckResult{Validated: 1, Failed: 1}))
+	require.Equal(t, HealthNoop, policy.Decide(HealthCheckResult{Unavailable: true}))
+}
+
+func TestConsecutiveFailureHealthPolicy(t *testing.T) {
+	policy := NewConsecutiveFailureHealthPolicy(2, 3)
+	failing := HealthCheckResult{Validated: 5, Failed: 1}
+	require.Equal(t, HealthDestroyFailed, policy.Decide(failing))
+	require.Equal(t, HealthReset, policy.Decide(HealthCheckResult{Unavailable: true}))
+	require.Equal(t, HealthEscalate, policy.Decide(failing))
+	require.Equal(t, HealthNoop, policy.Decide(HealthCheckResult{Validated: 5}))
+	require.Equal(t, HealthDestroyFailed, policy.Decide(failing), "a healthy check starts over")
+}
@@ -0,0 +1,84 @@
+package pool
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// HistogramMetric is emitted for values whose distribution matters, such as latencies.
+type HistogramMetric struct {
+	Key   string
+	Value float64
+}
+
+// observeQuery emits the latency of an operation and, when it failed, an error
+// count tagged with the SQLSTATE class. pgx.ErrNoRows is not an error here. It is a no-op unless InstrumentQueries is set.
+func (p *AuroraPGPool) observeQuery(operation string, start time.Time, err error) {
+	if !p.instrumentQueries || p.metricsEmitter == nil {
+		return
+	}
+	tags := []MetricsTag{{"operation", operation}, {"pool_role", string(p.role)}, {"pg_host", p.host}}
+	p.metricsEmitter(HistogramMetric{
+		"pg_aurora_custom_query_duration_ms",
+		float64(time.Since(start)) / float64(time.Millisecond),
+	}, tags)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		p.metricsEmitter(Metric{"pg_aurora_custom_query_error_count", 1},
+			append(tags, MetricsTag{"sqlstate_class", sqlStateClass(err)}))
+	}
+}
+
+// sqlStateClass returns the first two characters of the SQLSTATE of err, or "none"
+// for errors that did not come from the server.
+func sqlStateClass(err error) string {
+	code := sqlState(err)
+	if len(code) < 2 {
+		return "none"
+	}
+	return code[:2]
+}
+
+// instrumentedRows observes a query when its rows are closed or read to the end, so
+// the latency covers reading the results and errors returned while iterating are
+// counted.
+type instrumentedRows struct {
+	pgx.Rows
+	p      *AuroraPGPool
+	start  time.Time
+	closed bool
+}
+
+func (r *instrumentedRows) Close() {
+	r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		r.p.observeQuery("query", r.start, r.Rows.Err())
+	}
+}
+
+func (r *instrumentedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+// instrumentedBatchResults observes a batch when its results are closed.
+type instrumentedBatchResults struct {
+	pgx.BatchResults
+	p      *AuroraPGPool
+	start  time.Time
+	closed bool
+}
+
+func (b *instrumentedBatchResults) Close() error {
+	err := b.BatchResults.Close()
+	if !b.closed {
+		b.closed = true
+		b.p.observeQuery("batch", b.start, err)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuroraPGPool_ObserveQuery(t *testing.T) {
+	var emitted []interface{}
+	var emittedTags [][]MetricsTag
+	p := &AuroraPGPool{
+		role:              ReadWrite,
+		host:              "writer",
+		instrumentQueries: true,
+		metricsEmitter: func(metric interface{}, tags []MetricsTag) {
+			emitted = append(emitted, metric)
+			emittedTags = append(emittedTags, tags)
+		},
+	}
+
+	p.observeQuery("exec", time.Now().Add(-10*time.Millisecond), nil)
+	require.Len(t, emitted, 1)
+	histogram := emitted[0].(HistogramMetric)
+	require.Equal(t, "pg_aurora_custom_query_duration_ms", histogram.Key)
+	require.GreaterOrEqual(t, histogram.Value, 10.0)
+	require.Equal(t, []MetricsTag{{"operation", "exec"}, {"pool_role", "rw"}, {"pg_host", "writer"}}, emittedTags[0])
+
+	p.observeQuery("query_row", time.Now(), pgx.ErrNoRows)
+	require.Len(t, emitted, 2, "no rows is not an error")
+
+	p.observeQuery("query", time.Now(), &pgconn.PgError{Code: "40001"})
+	require.Len(t, emitted, 4)
+	require.Equal(t, Metric{"pg_aurora_custom_query_error_count", 1}, emitted[3])
+	require.Contains(t, emittedTags[3], MetricsTag{"sqlstate_class", "40"})
+
+	p.instrumentQueries = false
+	p.observeQuery("exec", time.Now(), nil)
+	require.Len(t, emitted, 4)
+}
+
+func TestInstrumentedRows_ObservesEndOfRows(t *testing.T) {
+	var emitted []interface{}
+	p := &AuroraPGPool{
+		instrumentQueries: true,
+		metricsEmitter: func(metric interface{}, tags []MetricsTag) {
+			emitted = append(emitted, metric)
+		},
+	}
+	rows := &instrumentedRows{Rows: errorRows{err: &pgconn.PgError{Code: "57P01"}}, p: p, start: time.Now()}
+	require.False(t, rows.Next())
+	require.Len(t, emitted, 2, "the query is observed once the rows are read")
+	require.Equal(t, Metric{"pg_aurora_custom_query_error_count", 1}, emitted[1])
+	rows.Close()
+	require.Len(t, emitted, 2)
+}
+
+func TestSQLStateClass(t *testing.T) {
+	require.Equal(t, "57", sqlStateClass(&pgconn.PgError{Code: "57P01"}))
+	require.Equal(t, "none", sqlStateClass(context.DeadlineExceeded))
+	require.Equal(t, "none", sqlStateClass(errors.New("boom")))
+}
@@ -0,0 +1,78 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// livenessPeriods is how many periods a background loop may go without completing
+// a tick before the pool is no longer alive.
+var livenessPeriods = 3
+
+// loopTracker records when the background loops of a pool last completed a tick.
+type loopTracker struct {
+	mu    sync.Mutex
+	loops map[string]loopBeat
+}
+
+type loopBeat struct {
+	period time.Duration
+	last   time.Time
+}
+
+// beat records that loop, which ticks every period, is running.
+func (t *loopTracker) beat(loop string, period time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loops == nil {
+		t.loops = make(map[string]loopBeat)
+	}
+	t.loops[loop] = loopBeat{period: period, last: time.Now()}
+}
+
+// check returns an error naming a loop that missed livenessPeriods ticks.
+func (t *loopTracker) check(now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for loop, beat := range t.loops {
+		if since := now.Sub(beat.last); since > time.Duration(livenessPeriods)*beat.period {
+			return fmt.Errorf("background %s has not run for %s", loop, since.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// Alive reports whether the background loops of the pool still run: the query
+// health check, topology check, DNS refresh and credentials check. It does not
+// touch the database.
+func (p *AuroraPGPool) Alive() error {
+	if p.isClosed() {
+		return ErrPoolClosed
+	}
+	return p.loops.check(time.Now())
+}
+
+// Alive reports whether replica discovery and the background loops of the cluster
+// and replica pools still run.
+func (r *ReaderPool) Alive() error {
+	select {
+	case <-r.closeChan:
+		return ErrPoolClosed
+	default:
+	}
+	if err := r.loops.check(time.Now()); err != nil {
+		return err
+	}
+	if err := r.clusterPool.Alive(); err != nil {
+		return err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rep := range r.replicas {
+		if err := rep.pool.Alive(); err != nil {
+			return fmt.Errorf("replica %s: %w", rep.serverID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoopTracker(t *testing.T) {
+	var loops loopTracker
+	now := time.Now()
+	require.NoError(t, loops.check(now), "pools without loops are alive")
+
+	loops.beat("topology check", time.Second)
+	require.NoError(t, loops.check(now.Add(time.Second*2)))
+	require.EqualError(t, loops.check(now.Add(time.Second*4)), "background topology check has not run for 4s")
+
+	p := &AuroraPGPool{}
+	require.NoError(t, p.Alive())
+	p.closed = 1
+	require.ErrorIs(t, p.Alive(), ErrPoolClosed)
+}
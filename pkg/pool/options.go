@@ -0,0 +1,150 @@
+package pool
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Option configures a pool built with New.
+type Option func(*options)
+
+type options struct {
+	config Config
+	logger *zap.Logger
+	pgx    []func(*pgxpool.Config)
+}
+
+// WithLogger sets the logger of the pool, which discards logs by default.
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// WithValidator enables the query health check with validator.
+func WithValidator(validator ValidationFunction) Option {
+	return func(o *options) { o.config.QueryValidator = validator }
+}
+
+// WithMetricsEmitter sets the function the pool emits its metrics with.
+func WithMetricsEmitter(emitter MetricsEmitterFunction) Option {
+	return func(o *options) { o.config.MetricsEmitter = emitter }
+}
+
+// WithRole sets the role of the pool, ReadWrite enables writer demotion detection.
+func WithRole(role PoolRole) Option {
+	return func(o *options) { o.config.Role = role }
+}
+
+// WithQueryHealthCheckPeriod sets how often the query health check runs.
+func WithQueryHealthCheckPeriod(period time.Duration) Option {
+	return func(o *options) { o.config.QueryHealthCheckPeriod = period }
+}
+
+// WithQueryValidationTimeout bounds every run of the validator.
+func WithQueryValidationTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.config.QueryValidationTimeout = timeout }
+}
+
+// WithTopologyCheckPeriod enables polling the Aurora topology for writer changes.
+func WithTopologyCheckPeriod(period time.Duration) Option {
+	return func(o *options) { o.config.TopologyCheckPeriod = period }
+}
+
+// WithRetry enables retrying idempotent calls on failover errors.
+func WithRetry(retry RetryConfig) Option {
+	return func(o *options) { o.config.Retry = &retry }
+}
+
+// WithTxRetry configures the attempts and backoff of RunInTx.
+func WithTxRetry(retry RetryConfig) Option {
+	return func(o *options) { o.config.TxRetry = &retry }
+}
+
+// WithDNSRefreshInterval enables recycling connections to addresses that left the
+// DNS answer of the host.
+func WithDNSRefreshInterval(interval time.Duration) Option {
+	return func(o *options) { o.config.DNSRefreshInterval = interval }
+}
+
+// WithCredentials supplies the password of new connections, reloaded every checkPeriod.
+func WithCredentials(source CredentialsSource, checkPeriod time.Duration) Option {
+	return func(o *options) {
+		o.config.Credentials = source
+		o.config.CredentialsCheckPeriod = checkPeriod
+	}
+}
+
+// WithHealthPolicy sets what the query health check does with its results.
+func WithHealthPolicy(policy HealthPolicy) Option {
+	return func(o *options) { o.config.HealthPolicy = policy }
+}
+
+// WithNewHealthPolicy sets the constructor of a stateful health policy, see
+// Config.NewHealthPolicy.
+func WithNewHealthPolicy(newPolicy func() HealthPolicy) Option {
+	return func(o *options) { o.config.NewHealthPolicy = newPolicy }
+}
+
+// WithCircuitBreaker enables failing calls fast while the database is unreachable.
+func WithCircuitBreaker(breaker CircuitBreakerConfig) Option {
+	return func(o *options) { o.config.CircuitBreaker = &breaker }
+}
+
+// WithAcquireValidation enables validating idle connections when they are acquired.
+func WithAcquireValidation(validation AcquireValidationConfig) Option {
+	return func(o *options) { o.config.AcquireValidation = &validation }
+}
+
+// WithSlowQuery enables logging slow queries.
+func WithSlowQuery(slowQuery SlowQueryConfig) Option {
+	return func(o *options) { o.config.SlowQuery = &slowQuery }
+}
+
+// WithInstrumentQueries enables the per-query latency and error metrics.
+func WithInstrumentQueries() Option {
+	return func(o *options) { o.config.InstrumentQueries = true }
+}
+
+// WithLazyConnect returns the pool before it connected and retries the initial
+// connection in the background.
+func WithLazyConnect(lazyConnect LazyConnectConfig) Option {
+	return func(o *options) { o.config.LazyConnect = &lazyConnect }
+}
+
+// WithPGXConfig amends the pgxpool config parsed from the DSN, e.g. to set MaxConns
+// or a tracer.
+func WithPGXConfig(configure func(*pgxpool.Config)) Option {
+	return func(o *options) { o.pgx = append(o.pgx, configure) }
+}
+
+// New parses dsn, applies opts and connects the pool. Invalid options are reported
+// as a *ConfigError, the effective configuration is available from EffectiveConfig.
+func New(ctx context.Context, dsn string, opts ...Option) (*AuroraPGPool, error) {
+	config, logger, err := newConfig(dsn, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuroraPool(ctx, config, logger)
+}
+
+func newConfig(dsn string, opts ...Option) (*Config, *zap.Logger, error) {
+	pgxConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		// the DSN is left out of the error since it may contain a password
+		return nil, nil, &ConfigError{Field: "DSN", Reason: "cannot be parsed"}
+	}
+	o := &options{logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	for _, configure := range o.pgx {
+		configure(pgxConfig)
+	}
+	o.config.PGXConfig = pgxConfig
+	if err := o.config.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &o.config, o.logger, nil
+}
@@ -6,8 +6,9 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
-	"reflect"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -39,33 +40,83 @@ type (
 		Key   string
 		Value string
 	}
-	// MetricsEmitterFunction the pool can emit the pgxpool.Stat or raw metrics
+	// MetricsEmitterFunction the pool can emit PoolStats or raw metrics
 	MetricsEmitterFunction func(metrics interface{}, tags []MetricsTag)
 )
 
 type AuroraPGPool struct {
-	innerPool                      *pgxpool.Pool
-	queryValidationFunc            ValidationFunction
-	logger                         *zap.Logger
-	queryHealthCheckPeriod         time.Duration
-	closeChan                      chan struct{}
-	closeOnce                      sync.Once
-	metricsEmitter                 MetricsEmitterFunction
-	minAvailableConnectionFailSize int
-	validationCountDestroyTrigger  int
-	queryValidationTimeout         time.Duration
+	innerPool              *pgxpool.Pool
+	queryValidationFunc    ValidationFunction
+	logger                 *zap.Logger
+	queryHealthCheckPeriod time.Duration
+	closeChan              chan struct{}
+	closeOnce              sync.Once
+	stopOnce               sync.Once
+	metricsEmitter         MetricsEmitterFunction
+	healthPolicy           HealthPolicy
+	healthAcquireTimeout   time.Duration
+	queryValidationTimeout time.Duration
+	topologyCheckPeriod    time.Duration
+	topologyMu             sync.Mutex
+	writerServerID         string
+	retryConfig            *RetryConfig
+	txRetryConfig          *RetryConfig
+	role                   PoolRole
+	dnsRefreshInterval     time.Duration
+	lookupFunc             pgconn.LookupFunc
+	dnsMu                  sync.RWMutex
+	resolvedAddrs          map[string]bool
+	credentials            CredentialsSource
+	credentialsCheckPeriod time.Duration
+	credMu                 sync.RWMutex
+	password               string
+	lastCredentialsReload  time.Time
+	resetMu                sync.Mutex
+	lastFailoverReset      time.Time
+	acquireTracer          AcquireTracer
+	host                   string
+	lastStatCounters       statCounters
+	instrumentQueries      bool
+	acquireValidation      *AcquireValidationConfig
+	breaker                *circuitBreaker
+	health                 healthTracker
+	lazyConnect            *LazyConnectConfig
+	connecting             int32
+	closed                 int32
+	conns                  connTracker
+	loops                  loopTracker
+	// config is the resolved config, before the pool installed its hooks
+	config Config
+}
+
+// EffectiveConfig returns the config the pool was built with, with its defaults
+// resolved. PGXConfig does not include the hooks installed by the pool.
+func (p *AuroraPGPool) EffectiveConfig() Config {
+	c := p.config
+	c.PGXConfig = p.config.PGXConfig.Copy()
+	return c
 }
 
 func (p *AuroraPGPool) Close() {
+	atomic.StoreInt32(&p.closed, 1)
+	p.stop()
 	p.closeOnce.Do(func() {
-		close(p.closeChan)
 		p.innerPool.Close()
+		p.closeHealth()
+	})
+}
+
+// stop ends the background checks.
+func (p *AuroraPGPool) stop() {
+	p.stopOnce.Do(func() {
+		close(p.closeChan)
 	})
 }
 
 func (p *AuroraPGPool) backgroundQueryHealthCheck() {
 	ticker := time.NewTicker(p.queryHealthCheckPeriod)
 	defer ticker.Stop()
+	p.loops.beat("query health check", p.queryHealthCheckPeriod)
 	for {
 		select {
 		case <-p.closeChan:
@@ -73,6 +124,7 @@ func (p *AuroraPGPool) backgroundQueryHealthCheck() {
 			return
 		case <-ticker.C:
 			p.checkQueryHealth()
+			p.loops.beat("query health check", p.queryHealthCheckPeriod)
 		}
 	}
 }
@@ -91,64 +143,129 @@ func (p *AuroraPGPool) checkQueryHealth() {
 		zap.Int64("max", int64(stats.MaxConns())))
 
 	if p.metricsEmitter != nil {
-		tags := []MetricsTag{{"pg_host", host}}
-		p.metricsEmitter(stats, tags)
+		tags := []MetricsTag{{"pg_host", host}, {"pool_role", string(p.role)}}
+		p.metricsEmitter(p.poolStats(stats), tags)
 	}
 
 	ctx := context.Background()
-	timedCtx, cancel := context.WithTimeout(ctx, time.Millisecond*500)
+	if p.role == ReadWrite {
+		p.checkWriterRecovery(ctx)
+	}
+	timedCtx, cancel := context.WithTimeout(ctx, p.healthAcquireTimeout)
 	defer cancel()
-	conns := p.AcquireAllIdle(timedCtx)
-	availableCount := len(conns)
-	if availableCount == 0 { // TODO: Retry logic
-		p.logger.Warn("Health check reported no available connections")
-		return
+	conns := p.innerPool.AcquireAllIdle(timedCtx)
+	if len(conns) == 0 {
+		// every connection is busy or the pool is empty, validate a new one
+		conn, err := p.innerPool.Acquire(timedCtx)
+		if err != nil {
+			p.logger.Warn("Health check reported no available connections", zap.Error(err))
+			p.applyHealthAction(ctx, HealthCheckResult{Unavailable: true, MaxConns: stats.MaxConns()}, nil, host)
+			return
+		}
+		conns = append(conns, conn)
 	}
 
 	p.logger.Debug("started checkQueryHealth run..")
-	destroyCount := 0
-	if p.queryValidationFunc != nil {
-		for _, conn := range conns {
-			validated := p.runValidator(ctx, conn, p.logger)
-			if !validated {
-				err := conn.Conn().Close(ctx)
-				if err != nil {
-					p.logger.Warn("Invalid Connection close operation resulted in error", zap.Error(err))
-				}
-				destroyCount++
-				p.logger.Sugar().Errorf("Connection validation healthcheck failed. destroyCount=%d",
-					destroyCount)
-			}
-			conn.Release()
+	var healthy, failed []*pgxpool.Conn
+	for _, conn := range conns {
+		if p.queryValidationFunc == nil || p.runValidator(ctx, conn, p.logger) {
+			healthy = append(healthy, conn)
+		} else {
+			failed = append(failed, conn)
+			p.logger.Sugar().Errorf("Connection validation healthcheck failed. failedCount=%d", len(failed))
 		}
 	}
+	for _, conn := range healthy {
+		conn.Release()
+	}
 	p.logger.Info("Connections pool state", zap.String("pg_host", host),
-		zap.Int("availableCount:", availableCount), zap.Int("destroyed", destroyCount))
-
-	if availableCount > p.minAvailableConnectionFailSize &&
-		destroyCount > p.validationCountDestroyTrigger {
-		p.logger.Sugar().Warnf("Resetting pool since > %d connections failed validation",
-			p.validationCountDestroyTrigger)
-		p.innerPool.Reset()
-		p.logger.Info("Pool reset complete")
-		if p.metricsEmitter != nil {
+		zap.Int("availableCount:", len(conns)), zap.Int("failed", len(failed)))
+	p.applyHealthAction(ctx, HealthCheckResult{
+		Validated: len(conns),
+		Failed:    len(failed),
+		MaxConns:  stats.MaxConns(),
+	}, failed, host)
+	p.logger.Debug("ended checkQueryHealth run..")
+}
+
+// applyHealthAction acts on the decision of the health policy and releases the
+// connections that failed validation.
+func (p *AuroraPGPool) applyHealthAction(ctx context.Context, result HealthCheckResult, failed []*pgxpool.Conn,
+	host string,
+) {
+	p.recordHealth(result)
+	p.observeHealthCheck(result)
+	action := p.healthPolicy.Decide(result)
+	for _, conn := range failed {
+		if action != HealthNoop {
+			if err := conn.Conn().Close(ctx); err != nil {
+				p.logger.Warn("Invalid Connection close operation resulted in error", zap.Error(err))
+			}
+		}
+		conn.Release()
+	}
+	if action != HealthReset && action != HealthEscalate {
+		return
+	}
+
+	p.logger.Warn("Resetting pool after health check", zap.String("pg_host", host),
+		zap.Stringer("action", action), zap.Int("failed", result.Failed),
+		zap.Bool("unavailable", result.Unavailable))
+	p.resetPool("health check " + action.String())
+	p.logger.Info("Pool reset complete")
+	if action == HealthEscalate {
+		p.logger.Error("Pool resets are not restoring healthy connections", zap.String("pg_host", host))
+	}
+	if p.metricsEmitter != nil {
+		go p.metricsEmitter(
+			Metric{"pg_aurora_custom_db_destroy_count", 1},
+			[]MetricsTag{{"pg_host", host}})
+		if action == HealthEscalate {
 			go p.metricsEmitter(
-				Metric{"pg_aurora_custom_db_destroy_count", 1},
+				Metric{"pg_aurora_custom_health_escalation_count", 1},
 				[]MetricsTag{{"pg_host", host}})
 		}
 	}
-	p.logger.Debug("ended checkQueryHealth run..")
 }
 
 func (p *AuroraPGPool) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
-	return p.innerPool.Acquire(ctx)
+	if err := p.allowCall(); err != nil {
+		return nil, err
+	}
+	conn, err := p.acquire(ctx)
+	p.recordCall(err)
+	return conn, err
+}
+
+// acquire acquires a connection for a call and traces it, its error is an acquireError.
+func (p *AuroraPGPool) acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	spanCtx := ctx
+	if p.acquireTracer != nil {
+		spanCtx = p.acquireTracer.TraceAcquireStart(ctx, p.host)
+	}
+	conn, err := p.innerPool.Acquire(ctx)
+	if p.acquireTracer != nil {
+		p.acquireTracer.TraceAcquireEnd(spanCtx, err)
+	}
+	if err != nil {
+		return nil, &acquireError{err}
+	}
+	return conn, nil
 }
 
 func (p *AuroraPGPool) AcquireFunc(ctx context.Context, f func(*pgxpool.Conn) error) error {
-	return p.innerPool.AcquireFunc(ctx, f)
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	return f(conn)
 }
 
 func (p *AuroraPGPool) AcquireAllIdle(ctx context.Context) []*pgxpool.Conn {
+	if p.allowCall() != nil {
+		return nil
+	}
 	return p.innerPool.AcquireAllIdle(ctx)
 }
 
@@ -161,87 +278,228 @@ func (p *AuroraPGPool) Stat() *pgxpool.Stat {
 }
 
 func (p *AuroraPGPool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
-	return p.innerPool.Exec(ctx, sql, arguments...)
+	start := time.Now()
+	var tag pgconn.CommandTag
+	err := p.retry(ctx, func() error {
+		conn, err := p.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Release()
+		tag, err = conn.Exec(ctx, sql, arguments...)
+		return err
+	})
+	p.observeQuery("exec", start, err)
+	return tag, err
 }
 
 func (p *AuroraPGPool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
-	return p.innerPool.Query(ctx, sql, args...)
+	start := time.Now()
+	var rows pgx.Rows
+	err := p.retry(ctx, func() error {
+		conn, err := p.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		rows, err = conn.Query(ctx, sql, args...)
+		if err != nil {
+			conn.Release()
+			return err
+		}
+		rows = &connRows{Rows: rows, p: p, conn: conn}
+		return nil
+	})
+	if err != nil {
+		p.observeQuery("query", start, err)
+		if rows == nil {
+			rows = errRows{err}
+		}
+		return rows, err
+	}
+	if p.instrumentQueries {
+		return &instrumentedRows{Rows: rows, p: p, start: start}, nil
+	}
+	return rows, nil
 }
 
 func (p *AuroraPGPool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
-	return p.innerPool.QueryRow(ctx, sql, args...)
+	return &auroraRow{p: p, ctx: ctx, sql: sql, args: args}
 }
 
 func (p *AuroraPGPool) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
-	return p.innerPool.SendBatch(ctx, b)
+	if err := p.allowCall(); err != nil {
+		return errBatchResults{err}
+	}
+	// the latency covers the acquire, which waits for new connections during failovers
+	start := time.Now()
+	var results pgx.BatchResults
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		p.observeError(err)
+		results = errBatchResults{err}
+	} else {
+		results = &connBatchResults{BatchResults: conn.SendBatch(ctx, b), p: p, conn: conn}
+	}
+	if p.instrumentQueries {
+		return &instrumentedBatchResults{BatchResults: results, p: p, start: start}
+	}
+	return results
 }
 
 func (p *AuroraPGPool) Begin(ctx context.Context) (pgx.Tx, error) {
-	return p.innerPool.Begin(ctx)
+	return p.BeginTx(ctx, pgx.TxOptions{})
 }
 
 func (p *AuroraPGPool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
-	return p.innerPool.BeginTx(ctx, txOptions)
+	if err := p.allowCall(); err != nil {
+		return nil, err
+	}
+	tx, err := p.beginTx(ctx, txOptions)
+	p.recordCall(err)
+	return tx, err
+}
+
+// beginTx begins a transaction on a connection that is released once the
+// transaction ends.
+func (p *AuroraPGPool) beginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTx(ctx, txOptions)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &connTx{Tx: tx, conn: conn}, nil
 }
 
 func (p *AuroraPGPool) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string,
 	rowSrc pgx.CopyFromSource,
 ) (int64, error) {
-	return p.innerPool.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	if err := p.allowCall(); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	var n int64
+	conn, err := p.acquire(ctx)
+	if err == nil {
+		n, err = conn.CopyFrom(ctx, tableName, columnNames, rowSrc)
+		conn.Release()
+	}
+	p.observeError(err)
+	p.observeQuery("copy_from", start, err)
+	return n, err
 }
 
 func (p *AuroraPGPool) Ping(ctx context.Context) error {
-	return p.innerPool.Ping(ctx)
+	if err := p.allowCall(); err != nil {
+		return err
+	}
+	err := p.innerPool.Ping(ctx)
+	p.recordCall(err)
+	return err
 }
 
 func (p *AuroraPGPool) Reset() {
-	p.innerPool.Reset()
+	p.resetPool("reset requested")
 }
 
+// NewAuroraPool validates config and connects the pool. The config is copied with
+// its defaults resolved, so it is not modified and may be reused.
 func NewAuroraPool(ctx context.Context, config *Config, logger *zap.Logger) (*AuroraPGPool, error) {
-	// Intentionally not being aggressive since we have 2 background check threads
-	config.PGXConfig.HealthCheckPeriod = time.Minute * 5
-	dbpool, err := pgxpool.NewWithConfig(ctx, config.PGXConfig)
-	if err != nil {
-		return nil, err
-	}
-	err = dbpool.Ping(ctx)
-	if err != nil {
+	if err := config.Validate(); err != nil {
 		return nil, err
 	}
+	resolved := config.resolve()
+	config = &resolved
 
-	queryValidationTimeout := config.QueryValidationTimeout
-	queryHealthCheckPeriod := config.QueryHealthCheckPeriod
-	minAvailableConnectionFailSize := config.MinAvailableConnectionFailSize
-	validationCountDestroyTrigger := config.ValidationCountDestroyTrigger
-
-	if reflect.ValueOf(config.QueryValidationTimeout).IsZero() {
-		queryValidationTimeout = defaultQueryValidationTimeout
+	p := &AuroraPGPool{
+		logger:                 logger,
+		queryValidationFunc:    config.QueryValidator,
+		queryHealthCheckPeriod: config.QueryHealthCheckPeriod,
+		metricsEmitter:         config.MetricsEmitter,
+		queryValidationTimeout: config.QueryValidationTimeout,
+		healthPolicy:           config.HealthPolicy,
+		healthAcquireTimeout:   config.HealthAcquireTimeout,
+		topologyCheckPeriod:    config.TopologyCheckPeriod,
+		retryConfig:            config.Retry,
+		txRetryConfig:          config.TxRetry,
+		role:                   config.Role,
+		dnsRefreshInterval:     config.DNSRefreshInterval,
+		credentials:            config.Credentials,
+		credentialsCheckPeriod: config.CredentialsCheckPeriod,
+		instrumentQueries:      config.InstrumentQueries,
+		acquireValidation:      config.AcquireValidation,
+		lazyConnect:            config.LazyConnect,
+		host:                   config.PGXConfig.ConnConfig.Host,
+		config:                 resolved,
+		closeChan:              make(chan struct{}),
+	}
+	p.config.PGXConfig = config.PGXConfig.Copy()
+	if config.NewHealthPolicy != nil {
+		p.healthPolicy = config.NewHealthPolicy()
 	}
-	if reflect.ValueOf(config.QueryHealthCheckPeriod).IsZero() {
-		queryHealthCheckPeriod = defaultQueryHealthCheckPeriod
+	if config.SlowQuery != nil {
+		config.PGXConfig.ConnConfig.Tracer = chainTracers(config.PGXConfig.ConnConfig.Tracer,
+			newSlowQueryTracer(logger, config.SlowQuery, config.Role))
 	}
-	if reflect.ValueOf(config.MinAvailableConnectionFailSize).IsZero() {
-		minAvailableConnectionFailSize = defaultMinAvailableConnectionFailSize
+	if config.CircuitBreaker != nil {
+		p.breaker = newCircuitBreaker(config.CircuitBreaker, p.circuitStateChanged(config.CircuitBreaker.OnStateChange))
 	}
-	if reflect.ValueOf(config.ValidationCountDestroyTrigger).IsZero() {
-		validationCountDestroyTrigger = defaultValidationCountDestroyTrigger
+	if tracer, ok := config.PGXConfig.ConnConfig.Tracer.(AcquireTracer); ok {
+		p.acquireTracer = tracer
 	}
 
-	p := &AuroraPGPool{
-		logger:                         logger,
-		queryValidationFunc:            config.QueryValidator,
-		queryHealthCheckPeriod:         queryHealthCheckPeriod,
-		metricsEmitter:                 config.MetricsEmitter,
-		queryValidationTimeout:         queryValidationTimeout,
-		minAvailableConnectionFailSize: minAvailableConnectionFailSize,
-		validationCountDestroyTrigger:  validationCountDestroyTrigger,
-		closeChan:                      make(chan struct{}),
+	p.lookupFunc = config.PGXConfig.ConnConfig.LookupFunc
+	if p.lookupFunc == nil {
+		p.lookupFunc = net.DefaultResolver.LookupHost
+	}
+	if p.credentials != nil {
+		beforeConnect := config.PGXConfig.BeforeConnect
+		config.PGXConfig.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+			if err := p.beforeConnect(ctx, cc); err != nil {
+				return err
+			}
+			if beforeConnect != nil {
+				return beforeConnect(ctx, cc)
+			}
+			return nil
+		}
+	}
+	p.conns.maxConns = int(config.PGXConfig.MaxConns)
+	config.PGXConfig.BeforeAcquire = p.trackAcquire(config.PGXConfig.BeforeAcquire)
+	afterRelease := config.PGXConfig.AfterRelease
+	config.PGXConfig.AfterRelease = p.trackRelease(func(conn *pgx.Conn) bool {
+		if afterRelease != nil && !afterRelease(conn) {
+			return false
+		}
+		if p.credentials != nil && p.hasStalePassword(conn) {
+			return false
+		}
+		if p.dnsRefreshInterval > 0 && p.isStaleConn(conn) {
+			return false
+		}
+		return true
+	})
+
+	dbpool, err := pgxpool.NewWithConfig(ctx, config.PGXConfig)
+	if err != nil {
+		return nil, err
 	}
 	p.innerPool = dbpool
-	// Start the validator
-	if config.QueryValidator != nil {
-		go p.backgroundQueryHealthCheck()
+	if p.lazyConnect != nil {
+		// pgxpool opens its connections on demand, only the ping is deferred
+		p.connecting = 1
+		p.health.health = PoolHealth{State: Connecting, Reason: "connecting", Since: time.Now()}
+		go p.backgroundConnect()
+		return p, nil
+	}
+	if err := p.connect(ctx); err != nil {
+		dbpool.Close()
+		return nil, err
 	}
+	p.health.health = PoolHealth{State: Healthy, Reason: "connected", Since: time.Now()}
+	p.start()
 	return p, nil
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -77,6 +78,34 @@ func TestAuroraPGPool_ValidateRead(t *testing.T) {
 	rows.Close()
 }
 
+func TestAuroraPGPool_RunInTx(t *testing.T) {
+	setupPGEnv(t)
+	logger, err := setupLogging()
+	require.NoError(t, err)
+	pgc, err := loadPostgresConfig()
+	require.NoError(t, err)
+	config, err := pgxpool.ParseConfig(getDSN(pgc))
+	require.NoError(t, err)
+	ctx := context.Background()
+	testPool, err := NewAuroraPool(ctx, &Config{PGXConfig: config}, logger)
+	require.NoError(t, err)
+	defer testPool.Close()
+
+	err = testPool.RunInTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		exec, err := tx.Exec(ctx, writeQuery)
+		require.Equal(t, exec.RowsAffected(), int64(1))
+		return err
+	})
+	require.NoError(t, err)
+
+	require.Panics(t, func() {
+		_ = testPool.RunInTx(ctx, pgx.TxOptions{}, func(tx pgx.Tx) error {
+			panic("rolled back")
+		})
+	})
+	require.Equal(t, int32(0), testPool.Stat().AcquiredConns(), "panicking transaction released its connection")
+}
+
 func getDSN(pgc *pgConfig) string {
 	var dsn string
 	if !pgc.enableTLS {
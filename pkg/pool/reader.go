@@ -0,0 +1,451 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type ReaderBalanceStrategy int
+
+const (
+	RoundRobin ReaderBalanceStrategy = iota
+	LeastAcquired
+	LowestLag
+)
+
+var validReaderBalanceStrategies = map[string]ReaderBalanceStrategy{
+	"round-robin":    RoundRobin,
+	"least-acquired": LeastAcquired,
+	"lowest-lag":     LowestLag,
+}
+
+func ParseReaderBalanceStrategy(strategy string) (ReaderBalanceStrategy, error) {
+	if strategy == "" {
+		return RoundRobin, nil
+	}
+	if s, ok := validReaderBalanceStrategies[strategy]; ok {
+		return s, nil
+	}
+	return RoundRobin, fmt.Errorf("invalid reader balance strategy %q", strategy)
+}
+
+func (s ReaderBalanceStrategy) String() string {
+	for k, strategy := range validReaderBalanceStrategies {
+		if strategy == s {
+			return k
+		}
+	}
+	return "unknown"
+}
+
+var (
+	defaultReplicaDiscoveryPeriod = time.Second * 30
+	// replicaConnectTimeout bounds opening a discovered replica pool, so one that is
+	// unreachable does not hold up the others
+	replicaConnectTimeout = time.Second * 5
+)
+
+// InstanceHostFunction maps an Aurora cluster endpoint and a SERVER_ID reported by
+// aurora_replica_status() to the instance endpoint to connect to.
+type InstanceHostFunction func(clusterHost string, serverID string) string
+
+// AuroraInstanceHost derives an instance endpoint from a cluster, reader or custom
+// endpoint, e.g. ("koko.cluster-ro-abc.us-west-2.rds.amazonaws.com", "koko-2") returns
+// "koko-2.abc.us-west-2.rds.amazonaws.com". An empty string is returned when the
+// host is not an Aurora cluster endpoint.
+func AuroraInstanceHost(clusterHost string, serverID string) string {
+	parts := strings.SplitN(clusterHost, ".", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	domain := parts[1]
+	for _, prefix := range []string{"cluster-custom-", "cluster-ro-", "cluster-"} {
+		if strings.HasPrefix(domain, prefix) {
+			return serverID + "." + strings.TrimPrefix(domain, prefix)
+		}
+	}
+	return ""
+}
+
+type ReaderConfig struct {
+	// Config is used as a template for the cluster reader pool and every replica pool.
+	Config   *Config
+	Strategy ReaderBalanceStrategy
+	// DiscoveryPeriod is how often aurora_replica_status() is polled for replicas.
+	DiscoveryPeriod time.Duration
+	InstanceHost    InstanceHostFunction
+	// ReplicaMinConns replaces PGXConfig.MinConns in the replica pools when set, the
+	// cluster reader pool keeps the MinConns of Config.
+	ReplicaMinConns int32
+}
+
+type replica struct {
+	serverID string
+	host     string
+	lagMS    float64
+	pool     *AuroraPGPool
+}
+
+// ReaderPool balances reads across a pool per Aurora replica. The pool built from
+// the cluster reader endpoint is used for discovery and whenever no replica is known.
+type ReaderPool struct {
+	clusterPool     *AuroraPGPool
+	config          *Config
+	strategy        ReaderBalanceStrategy
+	discoveryPeriod time.Duration
+	instanceHost    InstanceHostFunction
+	replicaMinConns int32
+	logger          *zap.Logger
+	mu              sync.RWMutex
+	replicas        []*replica
+	next            uint64
+	closeChan       chan struct{}
+	closeOnce       sync.Once
+	loops           loopTracker
+}
+
+func (r *ReaderPool) Close() {
+	r.closeOnce.Do(func() {
+		close(r.closeChan)
+		r.mu.Lock()
+		replicas := r.replicas
+		r.replicas = nil
+		r.mu.Unlock()
+		for _, rep := range replicas {
+			rep.pool.Close()
+		}
+		r.clusterPool.Close()
+	})
+}
+
+// Shutdown shuts the cluster and replica pools down concurrently, see
+// AuroraPGPool.Shutdown. The report sums the reports of every pool.
+func (r *ReaderPool) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	var report ShutdownReport
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.closeChan)
+		r.mu.Lock()
+		pools := []*AuroraPGPool{r.clusterPool}
+		for _, rep := range r.replicas {
+			pools = append(pools, rep.pool)
+		}
+		r.replicas = nil
+		r.mu.Unlock()
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, p := range pools {
+			wg.Add(1)
+			go func(p *AuroraPGPool) {
+				defer wg.Done()
+				poolReport, poolErr := p.Shutdown(ctx)
+				mu.Lock()
+				defer mu.Unlock()
+				report = report.Add(poolReport)
+				if err == nil {
+					err = poolErr
+				}
+			}(p)
+		}
+		wg.Wait()
+	})
+	return report, err
+}
+
+func (r *ReaderPool) backgroundDiscovery() {
+	ticker := time.NewTicker(r.discoveryPeriod)
+	defer ticker.Stop()
+	r.loops.beat("replica discovery", r.discoveryPeriod)
+	for {
+		select {
+		case <-r.closeChan:
+			r.logger.Info("backgroundDiscovery exited..")
+			return
+		case <-ticker.C:
+			r.discoverReplicas(context.Background())
+			r.loops.beat("replica discovery", r.discoveryPeriod)
+		}
+	}
+}
+
+var replicaDiscoveryQuery = `SELECT SERVER_ID, COALESCE(REPLICA_LAG_IN_MSEC, 0) FROM aurora_replica_status()
+     WHERE SESSION_ID <> 'MASTER_SESSION_ID' AND EXTRACT(EPOCH FROM(NOW() - LAST_UPDATE_TIMESTAMP)) <= 300`
+
+func (r *ReaderPool) discoverReplicas(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, r.discoveryPeriod)
+	defer cancel()
+	rows, err := r.clusterPool.Query(ctx, replicaDiscoveryQuery)
+	if err != nil {
+		r.logger.Warn("replica discovery failed", zap.Error(err))
+		return
+	}
+	lags := map[string]float64{}
+	for rows.Next() {
+		var serverID string
+		var lagMS float64
+		if err := rows.Scan(&serverID, &lagMS); err != nil {
+			rows.Close()
+			r.logger.Warn("replica discovery scan failed", zap.Error(err))
+			return
+		}
+		lags[serverID] = lagMS
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		r.logger.Warn("replica discovery failed", zap.Error(err))
+		return
+	}
+
+	r.mu.RLock()
+	current := r.replicas
+	r.mu.RUnlock()
+
+	known := map[string]*replica{}
+	var retained, removed []*replica
+	for _, rep := range current {
+		if lag, ok := lags[rep.serverID]; ok {
+			retained = append(retained, &replica{serverID: rep.serverID, host: rep.host, lagMS: lag, pool: rep.pool})
+			known[rep.serverID] = rep
+		} else {
+			removed = append(removed, rep)
+		}
+	}
+	clusterHost := r.clusterPool.Config().ConnConfig.Host
+	for serverID, lag := range lags {
+		if _, ok := known[serverID]; ok {
+			continue
+		}
+		host := r.instanceHost(clusterHost, serverID)
+		if host == "" {
+			r.logger.Warn("cannot derive replica instance endpoint", zap.String("cluster_host", clusterHost),
+				zap.String("server_id", serverID))
+			continue
+		}
+		connectCtx, cancelConnect := context.WithTimeout(ctx, replicaConnectTimeout)
+		p, err := r.openReplicaPool(connectCtx, host)
+		cancelConnect()
+		if err != nil {
+			r.logger.Warn("failed to open replica pool", zap.String("pg_host", host), zap.Error(err))
+			continue
+		}
+		r.logger.Info("added replica to reader pool", zap.String("server_id", serverID), zap.String("pg_host", host))
+		retained = append(retained, &replica{serverID: serverID, host: host, lagMS: lag, pool: p})
+	}
+
+	r.mu.Lock()
+	select {
+	case <-r.closeChan:
+		// Close ran while discovering, only the pools opened by this run are left to close
+		r.mu.Unlock()
+		for _, rep := range retained {
+			if _, ok := known[rep.serverID]; !ok {
+				rep.pool.Close()
+			}
+		}
+		return
+	default:
+	}
+	r.replicas = retained
+	r.mu.Unlock()
+
+	for _, rep := range removed {
+		r.logger.Info("removed replica from reader pool", zap.String("server_id", rep.serverID),
+			zap.String("pg_host", rep.host))
+		// Close blocks until acquired connections are released
+		go rep.pool.Close()
+	}
+}
+
+func (r *ReaderPool) openReplicaPool(ctx context.Context, host string) (*AuroraPGPool, error) {
+	pgxConfig := r.config.PGXConfig.Copy()
+	pgxConfig.ConnConfig.Host = host
+	pgxConfig.ConnConfig.Fallbacks = nil
+	if r.replicaMinConns > 0 {
+		pgxConfig.MinConns = r.replicaMinConns
+	}
+	if pgxConfig.ConnConfig.TLSConfig != nil {
+		pgxConfig.ConnConfig.TLSConfig = pgxConfig.ConnConfig.TLSConfig.Clone()
+		pgxConfig.ConnConfig.TLSConfig.ServerName = host
+	}
+	config := *r.config
+	config.PGXConfig = pgxConfig
+	// replicas are opened once discovered, one that cannot connect is skipped
+	config.LazyConnect = nil
+	return NewAuroraPool(ctx, &config, r.logger.With(zap.String("pg_host", host)))
+}
+
+// pick returns the pool the next call is routed to.
+func (r *ReaderPool) pick() PGXConnPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rep := r.pickReplica()
+	if rep == nil {
+		return r.clusterPool
+	}
+	return rep.pool
+}
+
+// usable reports whether calls can be routed to the replica. An unavailable pool or
+// one whose circuit is not closed would fail them until the next discovery.
+func (rep *replica) usable() bool {
+	return rep.pool.Health().State != Unavailable && rep.pool.CircuitState() == CircuitClosed
+}
+
+// pickReplica returns the replica the next call is routed to among the usable ones,
+// or nil when none is.
+func (r *ReaderPool) pickReplica() *replica {
+	replicas := make([]*replica, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		if rep.usable() {
+			replicas = append(replicas, rep)
+		}
+	}
+	if len(replicas) == 0 {
+		return nil
+	}
+	switch r.strategy {
+	case LeastAcquired:
+		best := replicas[0]
+		bestAcquired := best.pool.Stat().AcquiredConns()
+		for _, rep := range replicas[1:] {
+			if acquired := rep.pool.Stat().AcquiredConns(); acquired < bestAcquired {
+				best, bestAcquired = rep, acquired
+			}
+		}
+		return best
+	case LowestLag:
+		best := replicas[0]
+		for _, rep := range replicas[1:] {
+			if rep.lagMS < best.lagMS {
+				best = rep
+			}
+		}
+		return best
+	default:
+		n := atomic.AddUint64(&r.next, 1)
+		return replicas[(n-1)%uint64(len(replicas))]
+	}
+}
+
+// ReplicaStats returns the pool stats of every discovered replica keyed by SERVER_ID.
+func (r *ReaderPool) ReplicaStats() map[string]*pgxpool.Stat {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	stats := make(map[string]*pgxpool.Stat, len(r.replicas))
+	for _, rep := range r.replicas {
+		stats[rep.serverID] = rep.pool.Stat()
+	}
+	return stats
+}
+
+func (r *ReaderPool) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	return r.pick().Acquire(ctx)
+}
+
+func (r *ReaderPool) AcquireFunc(ctx context.Context, f func(*pgxpool.Conn) error) error {
+	return r.pick().AcquireFunc(ctx, f)
+}
+
+func (r *ReaderPool) AcquireAllIdle(ctx context.Context) []*pgxpool.Conn {
+	return r.pick().AcquireAllIdle(ctx)
+}
+
+func (r *ReaderPool) Config() *pgxpool.Config {
+	return r.clusterPool.Config()
+}
+
+func (r *ReaderPool) Stat() *pgxpool.Stat {
+	return r.clusterPool.Stat()
+}
+
+func (r *ReaderPool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return r.pick().Exec(ctx, sql, arguments...)
+}
+
+func (r *ReaderPool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return r.pick().Query(ctx, sql, args...)
+}
+
+func (r *ReaderPool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return r.pick().QueryRow(ctx, sql, args...)
+}
+
+func (r *ReaderPool) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return r.pick().SendBatch(ctx, b)
+}
+
+func (r *ReaderPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return r.pick().Begin(ctx)
+}
+
+func (r *ReaderPool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	return r.pick().BeginTx(ctx, txOptions)
+}
+
+func (r *ReaderPool) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string,
+	rowSrc pgx.CopyFromSource,
+) (int64, error) {
+	return r.pick().CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
+
+func (r *ReaderPool) Ping(ctx context.Context) error {
+	return r.clusterPool.Ping(ctx)
+}
+
+// Health returns the health of the cluster pool, which serves reads when no
+// replica is available.
+func (r *ReaderPool) Health() PoolHealth {
+	return r.clusterPool.Health()
+}
+
+func (r *ReaderPool) Reset() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rep := range r.replicas {
+		rep.pool.Reset()
+	}
+	r.clusterPool.Reset()
+}
+
+func NewReaderPool(ctx context.Context, config *ReaderConfig, logger *zap.Logger) (*ReaderPool, error) {
+	clusterPool, err := NewAuroraPool(ctx, config.Config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryPeriod := config.DiscoveryPeriod
+	if discoveryPeriod <= 0 {
+		discoveryPeriod = defaultReplicaDiscoveryPeriod
+	}
+	instanceHost := config.InstanceHost
+	if instanceHost == nil {
+		instanceHost = AuroraInstanceHost
+	}
+
+	// replica pools are opened from a copy, later changes of the caller do not reach them
+	replicaConfig := *config.Config
+	replicaConfig.PGXConfig = config.Config.PGXConfig.Copy()
+	r := &ReaderPool{
+		clusterPool:     clusterPool,
+		config:          &replicaConfig,
+		strategy:        config.Strategy,
+		discoveryPeriod: discoveryPeriod,
+		instanceHost:    instanceHost,
+		replicaMinConns: config.ReplicaMinConns,
+		logger:          logger,
+		closeChan:       make(chan struct{}),
+	}
+	r.discoverReplicas(ctx)
+	go r.backgroundDiscovery()
+	return r, nil
+}
@@ -0,0 +1,109 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAuroraInstanceHost(t *testing.T) {
+	tests := []struct {
+		clusterHost string
+		want        string
+	}{
+		{"koko.cluster-ro-abc.us-west-2.rds.amazonaws.com", "koko-2.abc.us-west-2.rds.amazonaws.com"},
+		{"koko.cluster-abc.us-west-2.rds.amazonaws.com", "koko-2.abc.us-west-2.rds.amazonaws.com"},
+		{"reporting.cluster-custom-abc.us-west-2.rds.amazonaws.com", "koko-2.abc.us-west-2.rds.amazonaws.com"},
+		{"localhost", ""},
+		{"db.example.com", ""},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, AuroraInstanceHost(tt.clusterHost, "koko-2"), tt.clusterHost)
+	}
+}
+
+func TestReaderPool_PickReplica(t *testing.T) {
+	replicas := []*replica{
+		{serverID: "koko-1", lagMS: 20, pool: &AuroraPGPool{}},
+		{serverID: "koko-2", lagMS: 5, pool: &AuroraPGPool{}},
+		{serverID: "koko-3", lagMS: 10, pool: &AuroraPGPool{}},
+	}
+
+	r := &ReaderPool{strategy: RoundRobin, replicas: replicas}
+	var picked []string
+	for i := 0; i < 4; i++ {
+		picked = append(picked, r.pickReplica().serverID)
+	}
+	require.Equal(t, []string{"koko-1", "koko-2", "koko-3", "koko-1"}, picked)
+
+	r = &ReaderPool{strategy: LowestLag, replicas: replicas}
+	require.Equal(t, "koko-2", r.pickReplica().serverID)
+
+	r = &ReaderPool{strategy: LowestLag}
+	require.Nil(t, r.pickReplica())
+}
+
+func TestReaderPool_PickReplicaSkipsUnusable(t *testing.T) {
+	unavailable := &AuroraPGPool{}
+	unavailable.health.health.State = Unavailable
+	circuitOpen := &AuroraPGPool{logger: zap.NewNop()}
+	circuitOpen.breaker = newCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 1}, circuitOpen.circuitStateChanged(nil))
+	circuitOpen.recordCall(io.ErrUnexpectedEOF)
+	replicas := []*replica{
+		{serverID: "koko-1", lagMS: 5, pool: unavailable},
+		{serverID: "koko-2", lagMS: 10, pool: &AuroraPGPool{}},
+		{serverID: "koko-3", lagMS: 1, pool: circuitOpen},
+	}
+
+	r := &ReaderPool{strategy: LowestLag, replicas: replicas}
+	require.Equal(t, "koko-2", r.pickReplica().serverID)
+	r = &ReaderPool{strategy: RoundRobin, replicas: replicas}
+	require.Equal(t, "koko-2", r.pickReplica().serverID)
+	require.Equal(t, "koko-2", r.pickReplica().serverID)
+
+	clusterPool := &AuroraPGPool{}
+	r = &ReaderPool{strategy: RoundRobin, replicas: replicas[:1], clusterPool: clusterPool}
+	require.Nil(t, r.pickReplica())
+	require.Same(t, clusterPool, r.pick(), "the cluster pool is used when no replica is usable")
+}
+
+func TestParseReaderBalanceStrategy(t *testing.T) {
+	s, err := ParseReaderBalanceStrategy("")
+	require.NoError(t, err)
+	require.Equal(t, RoundRobin, s)
+
+	s, err = ParseReaderBalanceStrategy("lowest-lag")
+	require.NoError(t, err)
+	require.Equal(t, LowestLag, s)
+	require.Equal(t, "lowest-lag", s.String())
+	require.Equal(t, "unknown", ReaderBalanceStrategy(42).String())
+
+	_, err = ParseReaderBalanceStrategy("random")
+	require.Error(t, err)
+}
+
+func TestNewReaderPool_CopiesConfig(t *testing.T) {
+	pgxConfig, err := pgxpool.ParseConfig(testDSN)
+	require.NoError(t, err)
+	pgxConfig.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	config := &Config{PGXConfig: pgxConfig, LazyConnect: &LazyConnectConfig{InitialInterval: time.Hour}}
+	r, err := NewReaderPool(context.Background(), &ReaderConfig{Config: config}, zap.NewNop())
+	require.NoError(t, err)
+	defer r.Close()
+
+	config.PGXConfig.MaxConns = 99
+	config.PGXConfig.ConnConfig.Host = "elsewhere"
+	require.NotSame(t, config, r.config)
+	require.NotSame(t, config.PGXConfig, r.config.PGXConfig)
+	require.NotEqual(t, int32(99), r.config.PGXConfig.MaxConns)
+	require.NotEqual(t, "elsewhere", r.config.PGXConfig.ConnConfig.Host)
+}
@@ -0,0 +1,247 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+var (
+	defaultRetryMaxAttempts     = 3
+	defaultRetryInitialInterval = time.Millisecond * 100
+	defaultRetryMaxInterval     = time.Second * 2
+	minFailoverResetInterval    = time.Second
+)
+
+// RetryConfig enables retrying calls marked with WithIdempotent when they fail
+// because of an Aurora failover. Zero values use the defaults.
+type RetryConfig struct {
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+type idempotentKey struct{}
+
+// WithIdempotent marks the calls made with the returned context as safe to retry.
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+// failoverSQLStates are raised while Aurora restarts or demotes an instance
+var failoverSQLStates = map[string]bool{
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+	"25006": true, // read_only_sql_transaction, the writer became a reader
+}
+
+// IsFailoverError reports whether err is a connection failure or an error
+// PostgreSQL raises while an Aurora instance fails over.
+func IsFailoverError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if code := sqlState(err); code != "" {
+		// Class 08 is connection exception
+		return failoverSQLStates[code] || strings.HasPrefix(code, "08")
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// sqlState returns the SQLSTATE of a PostgreSQL error or an empty string.
+func sqlState(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// resolveRetryConfig returns a copy of config with defaults applied.
+func resolveRetryConfig(config *RetryConfig) *RetryConfig {
+	resolved := &RetryConfig{}
+	if config != nil {
+		*resolved = *config
+	}
+	if resolved.MaxAttempts <= 0 {
+		resolved.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if resolved.InitialInterval <= 0 {
+		resolved.InitialInterval = defaultRetryInitialInterval
+	}
+	if resolved.MaxInterval <= 0 {
+		resolved.MaxInterval = defaultRetryMaxInterval
+	}
+	return resolved
+}
+
+func newBackOff(ctx context.Context, config *RetryConfig) backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = config.InitialInterval
+	b.MaxInterval = config.MaxInterval
+	b.MaxElapsedTime = 0
+	return backoff.WithContext(backoff.WithMaxRetries(b, uint64(config.MaxAttempts-1)), ctx)
+}
+
+// retry runs op and, if the call is idempotent and retries are enabled, runs it again
+// with backoff while it fails with failover errors.
+func (p *AuroraPGPool) retry(ctx context.Context, op func() error) error {
+	if err := p.allowCall(); err != nil {
+		return err
+	}
+	if p.retryConfig == nil || !isIdempotent(ctx) {
+		err := op()
+		p.observeError(err)
+		return err
+	}
+	attempt := 0
+	return backoff.Retry(func() error {
+		attempt++
+		err := op()
+		p.observeError(err)
+		if err == nil {
+			return nil
+		}
+		if !IsFailoverError(err) {
+			return backoff.Permanent(err)
+		}
+		p.logger.Warn("idempotent call failed during failover", zap.Int("attempt", attempt), zap.Error(err))
+		p.resetAfterFailover()
+		if p.metricsEmitter != nil {
+			go p.metricsEmitter(
+				Metric{"pg_aurora_custom_query_retry_count", 1},
+				[]MetricsTag{{"pg_host", p.Config().ConnConfig.Host}})
+		}
+		return err
+	}, newBackOff(ctx, p.retryConfig))
+}
+
+// allowFailoverReset reports whether the pool may be reset for a failover.
+// Concurrent failing calls reset the pool at most once per interval.
+func (p *AuroraPGPool) allowFailoverReset() bool {
+	p.resetMu.Lock()
+	defer p.resetMu.Unlock()
+	if time.Since(p.lastFailoverReset) < minFailoverResetInterval {
+		return false
+	}
+	p.lastFailoverReset = time.Now()
+	return true
+}
+
+// resetAfterFailover drops the pooled connections so retries connect to the new instance.
+func (p *AuroraPGPool) resetAfterFailover() {
+	if p.allowFailoverReset() {
+		p.resetPool("failover error")
+	}
+}
+
+// auroraRow defers QueryRow to Scan so its error is observed and, when
+// allowed, retried.
+type auroraRow struct {
+	p    *AuroraPGPool
+	ctx  context.Context
+	sql  string
+	args []interface{}
+}
+
+func (r *auroraRow) Scan(dest ...any) error {
+	start := time.Now()
+	err := r.p.retry(r.ctx, func() error {
+		conn, err := r.p.acquire(r.ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Release()
+		return conn.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	})
+	r.p.observeQuery("query_row", start, err)
+	return err
+}
+
+var _ pgx.Row = (*auroraRow)(nil)
+
+// connRows releases the connection of a query once its rows are closed or read to
+// the end. The server errors of a query, a failover or a demotion among them, mostly
+// show up while reading the rows, so the final error is observed like the errors of
+// the other calls.
+type connRows struct {
+	pgx.Rows
+	p    *AuroraPGPool
+	conn *pgxpool.Conn
+}
+
+func (r *connRows) Close() {
+	r.Rows.Close()
+	if r.conn == nil {
+		return
+	}
+	r.conn.Release()
+	r.conn = nil
+	if err := r.Rows.Err(); err != nil {
+		r.p.observeError(err)
+	}
+}
+
+func (r *connRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+func (r *connRows) Scan(dest ...any) error {
+	err := r.Rows.Scan(dest...)
+	if err != nil {
+		r.Close()
+	}
+	return err
+}
+
+func (r *connRows) Values() ([]any, error) {
+	values, err := r.Rows.Values()
+	if err != nil {
+		r.Close()
+	}
+	return values, err
+}
+
+// connBatchResults releases the connection of a batch once its results are closed
+// and observes the error of the batch then, the statements run while reading them.
+type connBatchResults struct {
+	pgx.BatchResults
+	p    *AuroraPGPool
+	conn *pgxpool.Conn
+}
+
+func (b *connBatchResults) Close() error {
+	err := b.BatchResults.Close()
+	if b.conn == nil {
+		return err
+	}
+	b.conn.Release()
+	b.conn = nil
+	b.p.observeError(err)
+	return err
+}
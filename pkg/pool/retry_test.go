@@ -0,0 +1,136 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIsFailoverError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "57P01"}, true},
+		{&pgconn.PgError{Code: "25006"}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "57P01"}), true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{syscall.ECONNRESET, true},
+		{io.ErrUnexpectedEOF, true},
+		{context.DeadlineExceeded, false},
+		{errors.New("no rows"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, IsFailoverError(tt.err), "%v", tt.err)
+	}
+}
+
+func TestAuroraPGPool_RetryOnlyIdempotentFailover(t *testing.T) {
+	p := &AuroraPGPool{
+		logger:      zap.NewNop(),
+		retryConfig: &RetryConfig{MaxAttempts: 3, InitialInterval: 1, MaxInterval: 1},
+	}
+	failover := &pgconn.PgError{Code: "57P01"}
+
+	calls := 0
+	err := p.retry(context.Background(), func() error {
+		calls++
+		return failover
+	})
+	require.ErrorIs(t, err, failover)
+	require.Equal(t, 1, calls, "calls without WithIdempotent are not retried")
+
+	calls = 0
+	unique := &pgconn.PgError{Code: "23505"}
+	err = p.retry(WithIdempotent(context.Background()), func() error {
+		calls++
+		return unique
+	})
+	require.ErrorIs(t, err, unique)
+	require.Equal(t, 1, calls, "non failover errors are not retried")
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	require.True(t, IsRetryableTxError(&pgconn.PgError{Code: "40001"}))
+	require.True(t, IsRetryableTxError(&pgconn.PgError{Code: "40P01"}))
+	require.True(t, IsRetryableTxError(&pgconn.PgError{Code: "57P01"}))
+	require.False(t, IsRetryableTxError(&pgconn.PgError{Code: "23505"}))
+	require.False(t, IsRetryableTxError(context.Canceled))
+}
+
+func TestAuroraPGPool_AllowFailoverReset(t *testing.T) {
+	p := &AuroraPGPool{role: ReadOnly}
+	require.True(t, p.allowFailoverReset())
+	require.False(t, p.allowFailoverReset(), "resets are limited to one per interval")
+
+	// only writer pools reset on read_only_sql_transaction errors
+	for _, role := range []PoolRole{ReadOnly, ""} {
+		p := &AuroraPGPool{role: role}
+		p.observeError(&pgconn.PgError{Code: "25006"})
+		require.True(t, p.lastFailoverReset.IsZero(), "role %q must not reset", role)
+	}
+}
+
+func TestIsReadOnlyTxError(t *testing.T) {
+	readOnly := &pgconn.PgError{Code: "25006"}
+	require.True(t, isReadOnlyTxError(pgx.TxOptions{AccessMode: pgx.ReadOnly}, readOnly))
+	require.False(t, isReadOnlyTxError(pgx.TxOptions{}, readOnly), "the writer may have been demoted")
+	require.False(t, isReadOnlyTxError(pgx.TxOptions{AccessMode: pgx.ReadOnly}, &pgconn.PgError{Code: "57P01"}))
+}
+
+// errorRows are rows whose query fails once they are read.
+type errorRows struct {
+	pgx.Rows
+	err error
+}
+
+func (r errorRows) Next() bool { return false }
+func (r errorRows) Close()     {}
+func (r errorRows) Err() error { return r.err }
+
+func TestConnRows_ObservesRowsError(t *testing.T) {
+	p := &AuroraPGPool{logger: zap.NewNop()}
+	p.breaker = newCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 1}, p.circuitStateChanged(nil))
+
+	rows := &connRows{Rows: errorRows{err: &pgconn.PgError{Code: "23505"}}, p: p, conn: &pgxpool.Conn{}}
+	require.False(t, rows.Next())
+	require.Equal(t, CircuitClosed, p.CircuitState(), "query errors do not open the circuit")
+
+	rows = &connRows{Rows: errorRows{err: io.ErrUnexpectedEOF}, p: p, conn: &pgxpool.Conn{}}
+	require.False(t, rows.Next())
+	rows.Close()
+	require.Equal(t, CircuitOpen, p.CircuitState(), "a failover while reading the rows is observed")
+}
+
+// errorBatchResults are batch results whose statements fail.
+type errorBatchResults struct {
+	pgx.BatchResults
+	err error
+}
+
+func (b errorBatchResults) Close() error { return b.err }
+
+func TestConnBatchResults_ObservesBatchError(t *testing.T) {
+	p := &AuroraPGPool{logger: zap.NewNop()}
+	p.breaker = newCircuitBreaker(&CircuitBreakerConfig{FailureThreshold: 2}, p.circuitStateChanged(nil))
+
+	results := &connBatchResults{BatchResults: errorBatchResults{err: io.ErrUnexpectedEOF}, p: p, conn: &pgxpool.Conn{}}
+	require.ErrorIs(t, results.Close(), io.ErrUnexpectedEOF)
+	require.ErrorIs(t, results.Close(), io.ErrUnexpectedEOF)
+	require.Equal(t, CircuitClosed, p.CircuitState(), "a batch is observed once")
+
+	results = &connBatchResults{BatchResults: errorBatchResults{err: io.ErrUnexpectedEOF}, p: p, conn: &pgxpool.Conn{}}
+	require.ErrorIs(t, results.Close(), io.ErrUnexpectedEOF)
+	require.Equal(t, CircuitOpen, p.CircuitState(), "batch failures open the circuit")
+}
@@ -0,0 +1,112 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrPoolClosed is returned without touching the database once the pool is shutting
+// down or closed.
+var ErrPoolClosed = errors.New("pool is closed")
+
+var (
+	drainPollInterval    = time.Millisecond * 10
+	cancelRequestTimeout = time.Second
+	// cancelGracePeriod is how long cancelled queries have to return their
+	// connections before Shutdown gives up on them
+	cancelGracePeriod = time.Second
+)
+
+// ShutdownReport describes what Shutdown waited for and aborted.
+type ShutdownReport struct {
+	// InFlight is the number of connections acquired when Shutdown was called.
+	InFlight int
+	// Cancelled is the number of connections that were still running a query at
+	// the deadline and were sent a cancel request.
+	Cancelled int
+	// Abandoned is the number of connections still acquired after the cancel
+	// requests. They are closed in the background once released.
+	Abandoned int
+	Duration  time.Duration
+}
+
+// Add sums the counts of two reports, Duration is the longest of both.
+func (r ShutdownReport) Add(other ShutdownReport) ShutdownReport {
+	r.InFlight += other.InFlight
+	r.Cancelled += other.Cancelled
+	r.Abandoned += other.Abandoned
+	if other.Duration > r.Duration {
+		r.Duration = other.Duration
+	}
+	return r
+}
+
+// Aborted reports whether in-flight work was cancelled or abandoned.
+func (r ShutdownReport) Aborted() bool {
+	return r.Cancelled > 0 || r.Abandoned > 0
+}
+
+func (p *AuroraPGPool) isClosed() bool {
+	return atomic.LoadInt32(&p.closed) == 1
+}
+
+// Shutdown stops accepting calls, which fail with ErrPoolClosed, and waits for the
+// acquired connections to be released until ctx is done. The queries still running
+// then are cancelled and ctx.Err() is returned with a report of what was aborted.
+func (p *AuroraPGPool) Shutdown(ctx context.Context) (ShutdownReport, error) {
+	start := time.Now()
+	atomic.StoreInt32(&p.closed, 1)
+	p.stop()
+	p.setHealth(Unavailable, "shutting down", nil)
+	report := ShutdownReport{InFlight: int(p.innerPool.Stat().AcquiredConns())}
+
+	if p.drain(ctx) {
+		p.Close()
+		report.Duration = time.Since(start)
+		return report, nil
+	}
+
+	inFlight := p.conns.inFlight()
+	cancelCtx, cancel := context.WithTimeout(context.Background(), cancelRequestTimeout)
+	for _, conn := range inFlight {
+		if err := conn.PgConn().CancelRequest(cancelCtx); err != nil {
+			p.logger.Warn("cancel request failed", zap.String("pg_host", p.host), zap.Error(err))
+			continue
+		}
+		report.Cancelled++
+	}
+	cancel()
+
+	graceCtx, cancel := context.WithTimeout(context.Background(), cancelGracePeriod)
+	defer cancel()
+	if p.drain(graceCtx) {
+		p.Close()
+	} else {
+		report.Abandoned = int(p.innerPool.Stat().AcquiredConns())
+		// Close blocks until the abandoned connections are released
+		go p.Close()
+	}
+	report.Duration = time.Since(start)
+	p.logger.Warn("pool shutdown aborted in-flight work", zap.String("pg_host", p.host),
+		zap.Int("in_flight", report.InFlight), zap.Int("cancelled", report.Cancelled),
+		zap.Int("abandoned", report.Abandoned))
+	return report, ctx.Err()
+}
+
+// drain waits until no connection is acquired, or returns false when ctx is done.
+func (p *AuroraPGPool) drain(ctx context.Context) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for p.innerPool.Stat().AcquiredConns() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownReport_Add(t *testing.T) {
+	report := ShutdownReport{InFlight: 2, Duration: time.Second}
+	require.False(t, report.Aborted())
+	report = report.Add(ShutdownReport{InFlight: 3, Cancelled: 1, Abandoned: 1, Duration: time.Millisecond})
+	require.Equal(t, ShutdownReport{InFlight: 5, Cancelled: 1, Abandoned: 1, Duration: time.Second}, report)
+	require.True(t, report.Aborted())
+}
+
+func TestAuroraPGPool_Shutdown(t *testing.T) {
+	p, err := New(context.Background(), testDSN,
+		WithLazyConnect(LazyConnectConfig{InitialInterval: time.Millisecond}),
+		WithPGXConfig(func(c *pgxpool.Config) {
+			c.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return nil, errors.New("connection refused")
+			}
+		}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	report, err := p.Shutdown(ctx)
+	require.NoError(t, err, "nothing was in flight")
+	require.Equal(t, 0, report.InFlight)
+	require.False(t, report.Aborted())
+
+	require.Equal(t, Unavailable, p.Health().State)
+	require.ErrorIs(t, p.Ping(context.Background()), ErrPoolClosed)
+	_, err = p.Exec(context.Background(), "SELECT 1")
+	require.ErrorIs(t, err, ErrPoolClosed)
+	p.Close()
+}
+
+func TestAuroraPGPool_ShutdownCancelsInFlight(t *testing.T) {
+	setupPGEnv(t)
+	logger, err := setupLogging()
+	require.NoError(t, err)
+	pgc, err := loadPostgresConfig()
+	require.NoError(t, err)
+	config, err := pgxpool.ParseConfig(getDSN(pgc))
+	require.NoError(t, err)
+	testPool, err := NewAuroraPool(context.Background(), &Config{PGXConfig: config}, logger)
+	require.NoError(t, err)
+
+	queryErr := make(chan error, 1)
+	go func() {
+		_, err := testPool.Exec(context.Background(), "SELECT pg_sleep(10)")
+		queryErr <- err
+	}()
+	require.Eventually(t, func() bool { return testPool.Stat().AcquiredConns() == 1 }, time.Second,
+		time.Millisecond*10, "the query is running")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	start := time.Now()
+	report, err := testPool.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 1, report.InFlight)
+	require.Equal(t, 1, report.Cancelled)
+	require.Equal(t, 0, report.Abandoned, "the cancelled query released its connection")
+	require.True(t, report.Aborted())
+	require.Less(t, time.Since(start), time.Second*5, "the query did not run to completion")
+
+	select {
+	case err := <-queryErr:
+		require.Equal(t, "57014", sqlState(err), "query_canceled")
+	case <-time.After(time.Second * 5):
+		t.Fatal("the query was not cancelled")
+	}
+}
@@ -0,0 +1,160 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// ParamRedaction controls how bind parameters appear in the slow query log.
+type ParamRedaction int
+
+const (
+	// RedactParams logs the number of parameters only.
+	RedactParams ParamRedaction = iota
+	// ParamTypes logs the Go type of every parameter.
+	ParamTypes
+	// ShowParams logs the parameter values.
+	ShowParams
+)
+
+var validParamRedactions = map[string]ParamRedaction{
+	"redact": RedactParams,
+	"types":  ParamTypes,
+	"show":   ShowParams,
+}
+
+func ParseParamRedaction(redaction string) (ParamRedaction, error) {
+	if redaction == "" {
+		return RedactParams, nil
+	}
+	if r, ok := validParamRedactions[redaction]; ok {
+		return r, nil
+	}
+	return RedactParams, fmt.Errorf("invalid parameter redaction %q", redaction)
+}
+
+// SlowQueryConfig enables logging the queries, batches and COPYs that take longer
+// than Threshold.
+type SlowQueryConfig struct {
+	Threshold time.Duration
+	// SampleRate is the fraction of slow queries that are logged, zero logs all of them.
+	SampleRate float64
+	Redaction  ParamRedaction
+}
+
+// slowQueryTracer logs slow operations. It is built on the pgx tracer hooks so it
+// covers every query made on a pool connection, including through entpgx.
+type slowQueryTracer struct {
+	logger     *zap.Logger
+	threshold  time.Duration
+	sampleRate float64
+	redaction  ParamRedaction
+	role       PoolRole
+	sample     func() float64
+}
+
+func newSlowQueryTracer(logger *zap.Logger, config *SlowQueryConfig, role PoolRole) *slowQueryTracer {
+	sampleRate := config.SampleRate
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = 1
+	}
+	return &slowQueryTracer{
+		logger:     logger,
+		threshold:  config.Threshold,
+		sampleRate: sampleRate,
+		redaction:  config.Redaction,
+		role:       role,
+		sample:     rand.Float64,
+	}
+}
+
+type slowQueryKey struct{}
+
+type slowQueryStart struct {
+	start time.Time
+	sql   string
+	args  []any
+}
+
+func (t *slowQueryTracer) params(args []any) zap.Field {
+	switch t.redaction {
+	case ShowParams:
+		return zap.Any("params", args)
+	case ParamTypes:
+		types := make([]string, len(args))
+		for i, arg := range args {
+			types[i] = fmt.Sprintf("%T", arg)
+		}
+		return zap.Strings("param_types", types)
+	default:
+		return zap.Int("param_count", len(args))
+	}
+}
+
+func (t *slowQueryTracer) log(ctx context.Context, conn *pgx.Conn, operation string, err error,
+	fields ...zap.Field,
+) {
+	started, ok := ctx.Value(slowQueryKey{}).(*slowQueryStart)
+	if !ok {
+		return
+	}
+	duration := time.Since(started.start)
+	if duration < t.threshold || t.sample() >= t.sampleRate {
+		return
+	}
+	fields = append(fields,
+		zap.String("operation", operation),
+		zap.String("sql", started.sql),
+		t.params(started.args),
+		zap.Duration("duration", duration),
+		zap.String("pool_role", string(t.role)),
+		zap.String("pg_host", connHost(conn)))
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	t.logger.Warn("slow query", fields...)
+}
+
+func (t *slowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryKey{}, &slowQueryStart{time.Now(), data.SQL, data.Args})
+}
+
+func (t *slowQueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.log(ctx, conn, "query", data.Err, zap.Int64("rows_affected", data.CommandTag.RowsAffected()))
+}
+
+func (t *slowQueryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return context.WithValue(ctx, slowQueryKey{}, &slowQueryStart{start: time.Now()})
+}
+
+// TraceBatchQuery collects the statements of the batch, which is logged as a whole.
+func (t *slowQueryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	if started, ok := ctx.Value(slowQueryKey{}).(*slowQueryStart); ok {
+		if started.sql != "" {
+			started.sql += "; "
+		}
+		started.sql += data.SQL
+	}
+}
+
+func (t *slowQueryTracer) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.log(ctx, conn, "batch", data.Err)
+}
+
+func (t *slowQueryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn,
+	data pgx.TraceCopyFromStartData,
+) context.Context {
+	return context.WithValue(ctx, slowQueryKey{}, &slowQueryStart{
+		start: time.Now(),
+		sql:   "COPY " + data.TableName.Sanitize(),
+	})
+}
+
+func (t *slowQueryTracer) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.log(ctx, conn, "copy_from", data.Err, zap.Int64("rows_affected", data.CommandTag.RowsAffected()))
+}
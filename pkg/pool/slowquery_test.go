@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSlowQueryTracer(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	tracer := newSlowQueryTracer(zap.New(core), &SlowQueryConfig{Threshold: 0, Redaction: ParamTypes}, ReadWrite)
+	ctx := context.Background()
+
+	queryCtx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{
+		SQL:  "SELECT * FROM foo WHERE id = $1",
+		Args: []any{"secret"},
+	})
+	tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{})
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	require.Equal(t, "SELECT * FROM foo WHERE id = $1", fields["sql"])
+	require.Equal(t, []interface{}{"string"}, fields["param_types"])
+	require.Equal(t, "rw", fields["pool_role"])
+	require.NotContains(t, fields, "params")
+
+	tracer.sample = func() float64 { return 0.9 }
+	tracer.sampleRate = 0.5
+	tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{})
+	require.Equal(t, 1, logs.Len(), "unsampled queries are not logged")
+
+	tracer.sampleRate = 1
+	tracer.threshold = time.Hour
+	tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{})
+	require.Equal(t, 1, logs.Len(), "fast queries are not logged")
+}
+
+func TestChainTracers(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	exporter := tracetest.NewInMemoryExporter()
+	queryTracer := NewQueryTracer(trace.NewTracerProvider(trace.WithSyncer(exporter)), ReadOnly)
+	slow := newSlowQueryTracer(zap.New(core), &SlowQueryConfig{}, ReadOnly)
+	require.Same(t, slow, chainTracers(nil, slow))
+
+	chained := chainTracers(queryTracer, slow)
+	ctx := chained.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	chained.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	require.Len(t, exporter.GetSpans(), 1)
+	require.Equal(t, 1, logs.Len())
+
+	_, ok := chained.(AcquireTracer)
+	require.True(t, ok)
+}
+
+func TestNewAuroraPool_DoesNotMutatePGXConfig(t *testing.T) {
+	config, logger, err := newConfig(testDSN,
+		WithLazyConnect(LazyConnectConfig{}),
+		WithSlowQuery(SlowQueryConfig{}),
+		WithCredentials(CredentialsFunc(func(context.Context) (string, error) { return "secret", nil }), time.Hour),
+		WithPGXConfig(func(c *pgxpool.Config) {
+			c.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return nil, errors.New("connection refused")
+			}
+		}))
+	require.NoError(t, err)
+	pgxConfig := config.PGXConfig
+
+	// A config reused as a template, as ReaderPool does for every replica, must not
+	// accumulate tracers and hooks
+	for i := 0; i < 2; i++ {
+		p, err := NewAuroraPool(context.Background(), config, logger)
+		require.NoError(t, err)
+		p.Close()
+	}
+	require.Same(t, pgxConfig, config.PGXConfig)
+	require.Nil(t, pgxConfig.ConnConfig.Tracer)
+	require.Nil(t, pgxConfig.BeforeConnect)
+	require.Nil(t, pgxConfig.BeforeAcquire)
+	require.Nil(t, pgxConfig.AfterRelease)
+}
@@ -0,0 +1,167 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is the number of transitions kept for a slow subscriber, later
+// ones are dropped.
+const subscriberBuffer = 16
+
+// HealthState is the health of a pool as seen by its background checks.
+type HealthState int
+
+const (
+	// Healthy means every validated connection passed.
+	Healthy HealthState = iota
+	// Degraded means some connections failed validation or the pool was just reset.
+	Degraded
+	// Resetting means the pool is closing its connections.
+	Resetting
+	// Unavailable means no connection could be validated or the circuit is open.
+	Unavailable
+	// Connecting means a lazily connected pool has not connected yet.
+	Connecting
+)
+
+func (s HealthState) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Resetting:
+		return "resetting"
+	case Unavailable:
+		return "unavailable"
+	case Connecting:
+		return "connecting"
+	}
+	return "unknown"
+}
+
+// PoolHealth is a snapshot of the health of a pool.
+type PoolHealth struct {
+	State  HealthState
+	Reason string
+	// Since is when the pool entered State.
+	Since time.Time
+	// LastValidation is when the query health check last validated connections,
+	// Validated and Failed are its counts.
+	LastValidation time.Time
+	Validated      int
+	Failed         int
+}
+
+// HealthTransition is sent to subscribers when the state of a pool changes.
+type HealthTransition struct {
+	From PoolHealth
+	To   PoolHealth
+}
+
+type healthTracker struct {
+	mu          sync.Mutex
+	health      PoolHealth
+	subscribers map[chan HealthTransition]struct{}
+	closed      bool
+}
+
+// Health returns the current health of the pool.
+func (p *AuroraPGPool) Health() PoolHealth {
+	p.health.mu.Lock()
+	defer p.health.mu.Unlock()
+	return p.health.health
+}
+
+// Subscribe returns a channel of health transitions and a function that cancels the
+// subscription. Transitions are dropped when the channel is full, and the channel
+// is closed when the pool is closed or the subscription is cancelled.
+func (p *AuroraPGPool) Subscribe() (<-chan HealthTransition, func()) {
+	ch := make(chan HealthTransition, subscriberBuffer)
+	t := &p.health
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if t.subscribers == nil {
+		t.subscribers = map[chan HealthTransition]struct{}{}
+	}
+	t.subscribers[ch] = struct{}{}
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.subscribers[ch]; ok {
+			delete(t.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// setHealth moves the pool to state and notifies the subscribers when the state
+// changed. update can amend the other fields of the snapshot.
+func (p *AuroraPGPool) setHealth(state HealthState, reason string, update func(*PoolHealth)) {
+	t := &p.health
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	from := t.health
+	to := from
+	to.State = state
+	to.Reason = reason
+	if update != nil {
+		update(&to)
+	}
+	if from.State == state {
+		t.health = to
+		return
+	}
+	to.Since = time.Now()
+	t.health = to
+	for ch := range t.subscribers {
+		select {
+		case ch <- HealthTransition{From: from, To: to}:
+		default:
+		}
+	}
+}
+
+// observeHealthCheck derives the state of the pool from a query health check.
+func (p *AuroraPGPool) observeHealthCheck(result HealthCheckResult) {
+	state, reason := Healthy, "all connections passed validation"
+	switch {
+	case result.Unavailable:
+		state, reason = Unavailable, "no connection available for validation"
+	case result.Failed > 0 && result.Failed == result.Validated:
+		state, reason = Unavailable, "all connections failed validation"
+	case result.Failed > 0:
+		state, reason = Degraded, fmt.Sprintf("%d of %d connections failed validation",
+			result.Failed, result.Validated)
+	}
+	p.setHealth(state, reason, func(h *PoolHealth) {
+		h.LastValidation = time.Now()
+		h.Validated = result.Validated
+		h.Failed = result.Failed
+	})
+}
+
+// resetPool resets the pool, which stays degraded until the next health check.
+func (p *AuroraPGPool) resetPool(reason string) {
+	p.setHealth(Resetting, reason, nil)
+	p.innerPool.Reset()
+	p.setHealth(Degraded, "pool reset: "+reason, nil)
+}
+
+// closeHealth closes the subscriptions.
+func (p *AuroraPGPool) closeHealth() {
+	t := &p.health
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	for ch := range t.subscribers {
+		close(ch)
+	}
+	t.subscribers = nil
+}
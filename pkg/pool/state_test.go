@@ -0,0 +1,52 @@
+package pool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuroraPGPool_Health(t *testing.T) {
+	p := &AuroraPGPool{}
+	transitions, cancel := p.Subscribe()
+
+	p.observeHealthCheck(HealthCheckResult{Validated: 5})
+	require.Equal(t, Healthy, p.Health().State)
+	require.Equal(t, 5, p.Health().Validated)
+	require.False(t, p.Health().LastValidation.IsZero())
+	require.Empty(t, transitions, "unchanged states are not sent")
+
+	p.observeHealthCheck(HealthCheckResult{Validated: 5, Failed: 2})
+	transition := <-transitions
+	require.Equal(t, Healthy, transition.From.State)
+	require.Equal(t, Degraded, transition.To.State)
+	require.Equal(t, "2 of 5 connections failed validation", transition.To.Reason)
+
+	p.observeHealthCheck(HealthCheckResult{Unavailable: true})
+	require.Equal(t, Unavailable, (<-transitions).To.State)
+	p.observeHealthCheck(HealthCheckResult{Validated: 2, Failed: 2})
+	require.Equal(t, "all connections failed validation", p.Health().Reason)
+
+	cancel()
+	_, open := <-transitions
+	require.False(t, open)
+	cancel()
+
+	transitions, _ = p.Subscribe()
+	p.closeHealth()
+	_, open = <-transitions
+	require.False(t, open, "subscriptions end when the pool is closed")
+	transitions, _ = p.Subscribe()
+	_, open = <-transitions
+	require.False(t, open)
+}
+
+func TestAuroraPGPool_HealthSlowSubscriber(t *testing.T) {
+	p := &AuroraPGPool{}
+	transitions, cancel := p.Subscribe()
+	defer cancel()
+	for i := 0; i < subscriberBuffer+5; i++ {
+		p.observeHealthCheck(HealthCheckResult{Unavailable: i%2 == 0, Validated: 1})
+	}
+	require.Len(t, transitions, subscriberBuffer, "transitions are dropped instead of blocking the pool")
+}
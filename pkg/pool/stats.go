@@ -0,0 +1,91 @@
+package pool
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolStats is emitted on every query health check. The connection counts are
+// point-in-time values, the other fields are the increase since the previous check.
+type PoolStats struct {
+	AcquiredConns     int32
+	ConstructingConns int32
+	IdleConns         int32
+	MaxConns          int32
+	TotalConns        int32
+
+	AcquireCount            int64
+	AcquireDuration         time.Duration
+	CanceledAcquireCount    int64
+	EmptyAcquireCount       int64
+	NewConnsCount           int64
+	MaxLifetimeDestroyCount int64
+	MaxIdleDestroyCount     int64
+}
+
+// statCounters are the cumulative counters of pgxpool.Stat.
+type statCounters struct {
+	acquireCount            int64
+	acquireDuration         time.Duration
+	canceledAcquireCount    int64
+	emptyAcquireCount       int64
+	newConnsCount           int64
+	maxLifetimeDestroyCount int64
+	maxIdleDestroyCount     int64
+}
+
+func newStatCounters(stat *pgxpool.Stat) statCounters {
+	return statCounters{
+		acquireCount:            stat.AcquireCount(),
+		acquireDuration:         stat.AcquireDuration(),
+		canceledAcquireCount:    stat.CanceledAcquireCount(),
+		emptyAcquireCount:       stat.EmptyAcquireCount(),
+		newConnsCount:           stat.NewConnsCount(),
+		maxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		maxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
+
+// counterDelta returns the increase of a counter. A counter that went backwards
+// was restarted, so all of its current value is new.
+func counterDelta(current, previous int64) int64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+func (c statCounters) sub(previous statCounters) statCounters {
+	return statCounters{
+		acquireCount:            counterDelta(c.acquireCount, previous.acquireCount),
+		acquireDuration:         time.Duration(counterDelta(int64(c.acquireDuration), int64(previous.acquireDuration))),
+		canceledAcquireCount:    counterDelta(c.canceledAcquireCount, previous.canceledAcquireCount),
+		emptyAcquireCount:       counterDelta(c.emptyAcquireCount, previous.emptyAcquireCount),
+		newConnsCount:           counterDelta(c.newConnsCount, previous.newConnsCount),
+		maxLifetimeDestroyCount: counterDelta(c.maxLifetimeDestroyCount, previous.maxLifetimeDestroyCount),
+		maxIdleDestroyCount:     counterDelta(c.maxIdleDestroyCount, previous.maxIdleDestroyCount),
+	}
+}
+
+// poolStats returns the current stats with the counters relative to the previous
+// call. It is only called from the query health check goroutine.
+func (p *AuroraPGPool) poolStats(stat *pgxpool.Stat) PoolStats {
+	counters := newStatCounters(stat)
+	delta := counters.sub(p.lastStatCounters)
+	p.lastStatCounters = counters
+	return PoolStats{
+		AcquiredConns:           stat.AcquiredConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		IdleConns:               stat.IdleConns(),
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		AcquireCount:            delta.acquireCount,
+		AcquireDuration:         delta.acquireDuration,
+		CanceledAcquireCount:    delta.canceledAcquireCount,
+		EmptyAcquireCount:       delta.emptyAcquireCount,
+		NewConnsCount:           delta.newConnsCount,
+		MaxLifetimeDestroyCount: delta.maxLifetimeDestroyCount,
+		MaxIdleDestroyCount:     delta.maxIdleDestroyCount,
+	}
+}
@@ -0,0 +1,20 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatCounters_Sub(t *testing.T) {
+	previous := statCounters{acquireCount: 10, acquireDuration: time.Second, newConnsCount: 5}
+	current := statCounters{acquireCount: 15, acquireDuration: 3 * time.Second, newConnsCount: 2, emptyAcquireCount: 1}
+
+	delta := current.sub(previous)
+	require.Equal(t, int64(5), delta.acquireCount)
+	require.Equal(t, 2*time.Second, delta.acquireDuration)
+	require.Equal(t, int64(1), delta.emptyAcquireCount)
+	require.Equal(t, int64(2), delta.newConnsCount, "a restarted counter reports its current value")
+	require.Equal(t, current, current.sub(statCounters{}))
+}
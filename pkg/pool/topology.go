@@ -0,0 +1,74 @@
+package pool
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var writerServerQuery = `SELECT SERVER_ID FROM aurora_replica_status() WHERE SESSION_ID = 'MASTER_SESSION_ID'`
+
+func (p *AuroraPGPool) backgroundTopologyCheck() {
+	ticker := time.NewTicker(p.topologyCheckPeriod)
+	defer ticker.Stop()
+	p.loops.beat("topology check", p.topologyCheckPeriod)
+	for {
+		select {
+		case <-p.closeChan:
+			p.logger.Info("backgroundTopologyCheck exited..")
+			return
+		case <-ticker.C:
+			p.checkTopology()
+			p.loops.beat("topology check", p.topologyCheckPeriod)
+		}
+	}
+}
+
+// WriterServerID returns the Aurora instance last reported as the writer, or an
+// empty string if the topology has not been observed yet.
+func (p *AuroraPGPool) WriterServerID() string {
+	p.topologyMu.Lock()
+	defer p.topologyMu.Unlock()
+	return p.writerServerID
+}
+
+// observeWriter records serverID as the current writer and reports the previous
+// writer and whether this observation is a change from a known writer.
+func (p *AuroraPGPool) observeWriter(serverID string) (string, bool) {
+	p.topologyMu.Lock()
+	defer p.topologyMu.Unlock()
+	previous := p.writerServerID
+	p.writerServerID = serverID
+	return previous, previous != "" && previous != serverID
+}
+
+func (p *AuroraPGPool) checkTopology() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryValidationTimeout)
+	defer cancel()
+	var serverID string
+	err := p.innerPool.QueryRow(ctx, writerServerQuery).Scan(&serverID)
+	if err != nil {
+		p.logger.Warn("topology check failed to read aurora_replica_status", zap.Error(err))
+		return
+	}
+	previous, changed := p.observeWriter(serverID)
+	if !changed {
+		return
+	}
+
+	host := p.Config().ConnConfig.Host
+	p.logger.Warn("Aurora writer changed, resetting pool", zap.String("pg_host", host),
+		zap.String("previous_writer", previous), zap.String("writer", serverID))
+	p.resetPool("writer changed")
+	// Re-establish a connection right away instead of waiting for the next caller
+	if err := p.innerPool.Ping(ctx); err != nil {
+		p.logger.Warn("Ping after writer change failed", zap.Error(err))
+	}
+	p.logger.Info("Pool reset after writer change complete")
+	if p.metricsEmitter != nil {
+		go p.metricsEmitter(
+			Metric{"pg_aurora_custom_writer_change_count", 1},
+			[]MetricsTag{{"pg_host", host}, {"writer", serverID}})
+	}
+}
@@ -0,0 +1,23 @@
+package pool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuroraPGPool_ObserveWriter(t *testing.T) {
+	p := &AuroraPGPool{}
+
+	previous, changed := p.observeWriter("instance-1")
+	require.False(t, changed, "first observation is not a change")
+	require.Equal(t, "", previous)
+
+	_, changed = p.observeWriter("instance-1")
+	require.False(t, changed)
+
+	previous, changed = p.observeWriter("instance-2")
+	require.True(t, changed)
+	require.Equal(t, "instance-1", previous)
+	require.Equal(t, "instance-2", p.WriterServerID())
+}
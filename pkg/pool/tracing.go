@@ -0,0 +1,246 @@
+package pool
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/kong/pg-aurora-client/pkg/pool"
+
+// AcquireTracer traces connection acquires. pgxpool does not trace them, so the
+// pool calls it for the acquires of Acquire, AcquireFunc, Exec, Query, QueryRow and
+// CopyFrom when ConnConfig.Tracer implements it.
+type AcquireTracer interface {
+	TraceAcquireStart(ctx context.Context, host string) context.Context
+	TraceAcquireEnd(ctx context.Context, err error)
+}
+
+// QueryTracer creates OpenTelemetry spans for queries, batches, COPY, connects and
+// acquires. Install it on PGXConfig.ConnConfig.Tracer.
+type QueryTracer struct {
+	tracer trace.Tracer
+	role   PoolRole
+}
+
+func NewQueryTracer(provider trace.TracerProvider, role PoolRole) *QueryTracer {
+	return &QueryTracer{tracer: provider.Tracer(tracerName), role: role}
+}
+
+func (t *QueryTracer) start(ctx context.Context, name, host string, attrs ...attribute.KeyValue) context.Context {
+	attrs = append(attrs,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("pg_host", host),
+		attribute.String("pool_role", string(t.role)))
+	ctx, _ = t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx
+}
+
+func endSpan(ctx context.Context, err error, attrs ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func connHost(conn *pgx.Conn) string {
+	if conn == nil {
+		return ""
+	}
+	return conn.Config().Host
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return t.start(ctx, "query", connHost(conn), attribute.String("db.statement", data.SQL))
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	endSpan(ctx, data.Err, attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+func (t *QueryTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	size := 0
+	if data.Batch != nil {
+		size = data.Batch.Len()
+	}
+	return t.start(ctx, "batch", connHost(conn), attribute.Int("db.batch_size", size))
+}
+
+// TraceBatchQuery records the statements of a batch as events of the batch span.
+func (t *QueryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	attrs := []attribute.KeyValue{attribute.String("db.statement", data.SQL)}
+	if data.Err != nil {
+		attrs = append(attrs, attribute.String("error", data.Err.Error()))
+	}
+	trace.SpanFromContext(ctx).AddEvent("batch query", trace.WithAttributes(attrs...))
+}
+
+func (t *QueryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	endSpan(ctx, data.Err)
+}
+
+func (t *QueryTracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn,
+	data pgx.TraceCopyFromStartData,
+) context.Context {
+	return t.start(ctx, "copy_from", connHost(conn),
+		attribute.String("db.sql.table", data.TableName.Sanitize()),
+		attribute.StringSlice("db.sql.columns", data.ColumnNames))
+}
+
+func (t *QueryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	endSpan(ctx, data.Err, attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+func (t *QueryTracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	host := ""
+	if data.ConnConfig != nil {
+		host = data.ConnConfig.Host
+	}
+	return t.start(ctx, "connect", host)
+}
+
+func (t *QueryTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	endSpan(ctx, data.Err)
+}
+
+func (t *QueryTracer) TraceAcquireStart(ctx context.Context, host string) context.Context {
+	return t.start(ctx, "acquire", host)
+}
+
+func (t *QueryTracer) TraceAcquireEnd(ctx context.Context, err error) {
+	endSpan(ctx, err)
+}
+
+// multiTracer calls several pgx tracers in order. pgx only takes one tracer per
+// connection config.
+type multiTracer []pgx.QueryTracer
+
+// chainTracers returns the tracers as a single pgx tracer, skipping nil ones.
+func chainTracers(tracers ...pgx.QueryTracer) pgx.QueryTracer {
+	var chained multiTracer
+	for _, tracer := range tracers {
+		if tracer != nil {
+			chained = append(chained, tracer)
+		}
+	}
+	if len(chained) == 1 {
+		return chained[0]
+	}
+	return chained
+}
+
+func (m multiTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	for _, t := range m {
+		ctx = t.TraceQueryStart(ctx, conn, data)
+	}
+	return ctx
+}
+
+func (m multiTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	for _, t := range m {
+		t.TraceQueryEnd(ctx, conn, data)
+	}
+}
+
+func (m multiTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	for _, t := range m {
+		if bt, ok := t.(pgx.BatchTracer); ok {
+			ctx = bt.TraceBatchStart(ctx, conn, data)
+		}
+	}
+	return ctx
+}
+
+func (m multiTracer) TraceBatchQuery(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchQueryData) {
+	for _, t := range m {
+		if bt, ok := t.(pgx.BatchTracer); ok {
+			bt.TraceBatchQuery(ctx, conn, data)
+		}
+	}
+}
+
+func (m multiTracer) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	for _, t := range m {
+		if bt, ok := t.(pgx.BatchTracer); ok {
+			bt.TraceBatchEnd(ctx, conn, data)
+		}
+	}
+}
+
+func (m multiTracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn,
+	data pgx.TraceCopyFromStartData,
+) context.Context {
+	for _, t := range m {
+		if ct, ok := t.(pgx.CopyFromTracer); ok {
+			ctx = ct.TraceCopyFromStart(ctx, conn, data)
+		}
+	}
+	return ctx
+}
+
+func (m multiTracer) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	for _, t := range m {
+		if ct, ok := t.(pgx.CopyFromTracer); ok {
+			ct.TraceCopyFromEnd(ctx, conn, data)
+		}
+	}
+}
+
+func (m multiTracer) TracePrepareStart(ctx context.Context, conn *pgx.Conn,
+	data pgx.TracePrepareStartData,
+) context.Context {
+	for _, t := range m {
+		if pt, ok := t.(pgx.PrepareTracer); ok {
+			ctx = pt.TracePrepareStart(ctx, conn, data)
+		}
+	}
+	return ctx
+}
+
+func (m multiTracer) TracePrepareEnd(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareEndData) {
+	for _, t := range m {
+		if pt, ok := t.(pgx.PrepareTracer); ok {
+			pt.TracePrepareEnd(ctx, conn, data)
+		}
+	}
+}
+
+func (m multiTracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	for _, t := range m {
+		if ct, ok := t.(pgx.ConnectTracer); ok {
+			ctx = ct.TraceConnectStart(ctx, data)
+		}
+	}
+	return ctx
+}
+
+func (m multiTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	for _, t := range m {
+		if ct, ok := t.(pgx.ConnectTracer); ok {
+			ct.TraceConnectEnd(ctx, data)
+		}
+	}
+}
+
+func (m multiTracer) TraceAcquireStart(ctx context.Context, host string) context.Context {
+	for _, t := range m {
+		if at, ok := t.(AcquireTracer); ok {
+			ctx = at.TraceAcquireStart(ctx, host)
+		}
+	}
+	return ctx
+}
+
+func (m multiTracer) TraceAcquireEnd(ctx context.Context, err error) {
+	for _, t := range m {
+		if at, ok := t.(AcquireTracer); ok {
+			at.TraceAcquireEnd(ctx, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := NewQueryTracer(provider, ReadOnly)
+	ctx := context.Background()
+
+	parentCtx, parent := provider.Tracer("test").Start(ctx, "handler")
+	queryCtx := tracer.TraceQueryStart(parentCtx, nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{})
+	acquireCtx := tracer.TraceAcquireStart(parentCtx, "reader")
+	tracer.TraceAcquireEnd(acquireCtx, errors.New("acquire timeout"))
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	query, acquire := spans[0], spans[1]
+	require.Equal(t, "query", query.Name)
+	require.Equal(t, parent.SpanContext().SpanID(), query.Parent.SpanID())
+	require.Contains(t, query.Attributes, attribute.String("db.statement", "SELECT 1"))
+	require.Contains(t, query.Attributes, attribute.String("pool_role", "ro"))
+
+	require.Equal(t, "acquire", acquire.Name)
+	require.Contains(t, acquire.Attributes, attribute.String("pg_host", "reader"))
+	require.Equal(t, codes.Error, acquire.Status.Code)
+}
+
+type countingAcquireTracer struct {
+	starts int32
+	errs   int32
+}
+
+func (c *countingAcquireTracer) TraceAcquireStart(ctx context.Context, _ string) context.Context {
+	atomic.AddInt32(&c.starts, 1)
+	return ctx
+}
+
+func (c *countingAcquireTracer) TraceAcquireEnd(_ context.Context, err error) {
+	if err != nil {
+		atomic.AddInt32(&c.errs, 1)
+	}
+}
+
+func TestAuroraPGPool_TracesAcquires(t *testing.T) {
+	p, err := New(context.Background(), testDSN,
+		WithLazyConnect(LazyConnectConfig{InitialInterval: time.Hour}),
+		WithPGXConfig(func(c *pgxpool.Config) {
+			c.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return nil, errors.New("connection refused")
+			}
+		}))
+	require.NoError(t, err)
+	defer p.Close()
+	atomic.StoreInt32(&p.connecting, 0)
+	tracer := &countingAcquireTracer{}
+	p.acquireTracer = tracer
+
+	ctx := context.Background()
+	calls := map[string]func() error{
+		"exec": func() error {
+			_, err := p.Exec(ctx, "SELECT 1")
+			return err
+		},
+		"query": func() error {
+			_, err := p.Query(ctx, "SELECT 1")
+			return err
+		},
+		"query row": func() error { return p.QueryRow(ctx, "SELECT 1").Scan() },
+		"copy from": func() error {
+			_, err := p.CopyFrom(ctx, pgx.Identifier{"t"}, []string{"c"}, pgx.CopyFromRows(nil))
+			return err
+		},
+	}
+	for name, call := range calls {
+		starts := atomic.LoadInt32(&tracer.starts)
+		require.Error(t, call(), name)
+		require.Greater(t, atomic.LoadInt32(&tracer.starts), starts, "%s traces its acquire", name)
+		require.Equal(t, atomic.LoadInt32(&tracer.starts), atomic.LoadInt32(&tracer.errs), name)
+	}
+}
@@ -0,0 +1,117 @@
+package pool
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// connTx releases the connection of a transaction once it is committed or rolled
+// back, like the transactions of pgxpool.
+type connTx struct {
+	pgx.Tx
+	conn *pgxpool.Conn
+}
+
+func (tx *connTx) Commit(ctx context.Context) error {
+	err := tx.Tx.Commit(ctx)
+	tx.release()
+	return err
+}
+
+func (tx *connTx) Rollback(ctx context.Context) error {
+	err := tx.Tx.Rollback(ctx)
+	tx.release()
+	return err
+}
+
+func (tx *connTx) release() {
+	if tx.conn != nil {
+		tx.conn.Release()
+		tx.conn = nil
+	}
+}
+
+// IsRetryableTxError reports whether a transaction that failed with err can be run
+// again: serialization failures, deadlocks and failover errors.
+func IsRetryableTxError(err error) bool {
+	switch sqlState(err) {
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return true
+	}
+	return IsFailoverError(err)
+}
+
+// RunInTx runs f in a transaction started with txOptions and commits it when f
+// returns nil. The transaction is rolled back when f returns an error or panics.
+// Retryable errors, see IsRetryableTxError, run f again in a new transaction so f
+// must not have side effects outside the transaction.
+func (p *AuroraPGPool) RunInTx(ctx context.Context, txOptions pgx.TxOptions, f func(pgx.Tx) error) error {
+	attempt := 0
+	return backoff.Retry(func() error {
+		attempt++
+		err := p.runTx(ctx, txOptions, f)
+		if isReadOnlyTxError(txOptions, err) {
+			return backoff.Permanent(err)
+		}
+		p.observeError(err)
+		if err == nil {
+			return nil
+		}
+		var permanent *backoff.PermanentError
+		if errors.As(err, &permanent) {
+			return err
+		}
+		if !IsRetryableTxError(err) {
+			return backoff.Permanent(err)
+		}
+		p.logger.Warn("retrying transaction", zap.Int("attempt", attempt), zap.Error(err))
+		if IsFailoverError(err) {
+			p.resetAfterFailover()
+		}
+		if p.metricsEmitter != nil {
+			go p.metricsEmitter(
+				Metric{"pg_aurora_custom_tx_retry_count", 1},
+				[]MetricsTag{{"pg_host", p.Config().ConnConfig.Host}})
+		}
+		return err
+	}, newBackOff(ctx, p.txRetryConfig))
+}
+
+// runTx runs f in a transaction, RunInTx records its result with the breaker.
+func (p *AuroraPGPool) runTx(ctx context.Context, txOptions pgx.TxOptions, f func(pgx.Tx) error) error {
+	if err := p.allowCall(); err != nil {
+		return err
+	}
+	tx, err := p.beginTx(ctx, txOptions)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				p.logger.Warn("rollback after panic failed", zap.Error(err))
+			}
+			panic(r)
+		}
+	}()
+
+	if err := f(tx); err != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			p.logger.Warn("rollback failed", zap.Error(rbErr))
+		}
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		// A connection lost during commit leaves the outcome unknown, never run f twice
+		if sqlState(err) == "" {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
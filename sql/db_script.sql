@@ -18,4 +18,8 @@ CREATE TABLE replication_canary(id bigint primary key, ts timestamp);
 
 INSERT INTO replication_canary values(1, CURRENT_TIMESTAMP);
 
+DROP TABLE IF EXISTS "session_token";
+
+CREATE TABLE session_token(id bigserial primary key, ts timestamp default CURRENT_TIMESTAMP);
+
 COMMIT;
\ No newline at end of file